@@ -7,14 +7,22 @@ import (
 	"os"
 	"time"
 
+	"github.com/JamesTiberiusKirk/lambdaban/internal/api/github"
 	"github.com/JamesTiberiusKirk/lambdaban/internal/api/healthcheck"
+	apiv1 "github.com/JamesTiberiusKirk/lambdaban/internal/api/v1"
 	"github.com/JamesTiberiusKirk/lambdaban/internal/config"
 	"github.com/JamesTiberiusKirk/lambdaban/internal/db"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/events"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/mail"
 	"github.com/JamesTiberiusKirk/lambdaban/internal/metrics"
 	"github.com/JamesTiberiusKirk/lambdaban/internal/middleware"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/share"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/account"
 	"github.com/JamesTiberiusKirk/lambdaban/internal/web/index"
 	"github.com/JamesTiberiusKirk/lambdaban/internal/web/notifications"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/stats"
 	"github.com/JamesTiberiusKirk/lambdaban/internal/web/todos"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/webhooks"
 	"github.com/alexedwards/scs/v2"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -35,7 +43,9 @@ func main() {
 	sessionManager := scs.New()
 	sessionManager.Lifetime = 24 * time.Hour
 
-	db, err := db.InitClient(logger, m,
+	broker := events.NewBroker()
+
+	db, err := db.InitClient(logger, m, broker,
 		config.DbUser, config.DbPass, config.DbHost, config.DbName,
 		true, time.Now)
 	if err != nil {
@@ -46,11 +56,23 @@ func main() {
 	defer cancel()
 
 	db.InitTTLCleanup(ctx, 10*time.Minute, 2*time.Hour)
+	db.InitAutoArchive(ctx, time.Hour)
+	db.InitNotificationCleanup(ctx, time.Hour, 30*24*time.Hour)
+	db.InitActivityCleanup(ctx, time.Hour, 90*24*time.Hour)
+
+	webhooks.NewWorker(logger, m, db, webhooks.NewClient(10*time.Second), time.Now).Start(ctx, 10*time.Second)
 
 	serverMux := http.NewServeMux()
 
-	nh := notifications.NewNotificationsHandler(logger, m, sessionManager)
+	nh := notifications.NewNotificationsHandler(logger, m, sessionManager, db, broker, todos.NewBoardRenderer(db))
+	nh.Start(ctx)
 	serverMux.HandleFunc("/notifications", nh.ServeSSE)
+	serverMux.HandleFunc("GET /notifications/bell", nh.ServeBell)
+	serverMux.HandleFunc("GET /notifications/inbox", nh.ServeInbox)
+	serverMux.HandleFunc("POST /notifications/inbox/read", nh.ServeMarkAllRead)
+	serverMux.HandleFunc("POST /notifications/inbox/{id}/read", nh.ServeMarkRead)
+	serverMux.HandleFunc("POST /notifications/inbox/clear", nh.ServeClear)
+	serverMux.HandleFunc("POST /notifications/preferences", nh.ServePreferences)
 
 	serverMux.Handle("/{$}", index.NewHandler(sessionManager))
 
@@ -61,15 +83,42 @@ func main() {
 	assets := servefiles.NewAssetHandler("./assets/").WithMaxAge(time.Hour)
 	serverMux.Handle("/assets/", http.StripPrefix("/assets/", assets))
 
-	todosHandler := todos.NewHandler(logger, db, sessionManager, nh)
+	signer := share.NewSigner(config.ShareSecret)
+	todosHandler := todos.NewHandler(logger, db, sessionManager, nh, broker, signer, config.BaseURL)
 	serverMux.Handle("/todos", todosHandler)
 	serverMux.Handle("/todos/", todosHandler)
+	serverMux.Handle("/share/", todosHandler)
+	serverMux.Handle("/calendar/", todosHandler)
+	serverMux.Handle("/feed/", todosHandler)
+	statsHandler := stats.NewHandler(logger, db, sessionManager)
+	serverMux.Handle("/todos/stats", statsHandler)
+	serverMux.Handle("/todos/stats/burn", statsHandler)
+
+	var mailer mail.Mailer = mail.NewSMTPMailer(config.SMTPHost, config.SMTPPort, config.SMTPUser, config.SMTPPass, config.MailFrom)
+	if config.MailLogOnly {
+		logger.Warn("MAIL_LOG_ONLY is set, emails are not sent")
+		mailer = mail.NewLogMailer(logger)
+	}
+	serverMux.Handle("/account/", account.NewHandler(logger, db, sessionManager, mailer, config.BaseURL))
 
 	serverMux.Handle("/api/healthcheck", healthcheck.NewHandler())
 
-	loggedServer := metrics.HTTPMiddleware(m, middleware.Logger(logger, serverMux))
+	csrfServer := middleware.CSRF(logger, sessionManager, m, serverMux, "/share/", "/calendar/", "/feed/")
+	sessionedServer := sessionManager.LoadAndSave(middleware.RevokedSessions(logger, sessionManager, db, csrfServer))
+
+	// The API is used by JSON clients which have no CSRF token, it only
+	// accepts JSON bodies instead, which other sites cannot send.
+	apiHandler := apiv1.NewHandler(logger, db, sessionManager)
+	apiServer := sessionManager.LoadAndSave(middleware.RevokedSessions(logger, sessionManager, db, apiHandler))
+
+	// Inbound hooks are authenticated by their signatures, they carry neither
+	// a session nor a CSRF token.
+	rootMux := http.NewServeMux()
+	rootMux.Handle("/api/v1/", apiServer)
+	rootMux.Handle("/hooks/github/", github.NewHandler(logger, db, nh))
+	rootMux.Handle("/", sessionedServer)
 
-	sessionedServer := sessionManager.LoadAndSave(loggedServer)
+	loggedServer := metrics.HTTPMiddleware(m, middleware.Logger(logger, rootMux))
 
 	metricsPort := os.Getenv("METRICS_PORT")
 	if metricsPort == "" {
@@ -86,7 +135,7 @@ func main() {
 	}
 
 	logger.Info("HTTP server listening", "port", port)
-	if err := http.ListenAndServe(":"+port, sessionedServer); err != nil {
+	if err := http.ListenAndServe(":"+port, loggedServer); err != nil {
 		logger.Error("failed to start server: ", "error", err)
 		return
 	}
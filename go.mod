@@ -12,6 +12,7 @@ require (
 	github.com/lib/pq v1.10.9
 	github.com/prometheus/client_golang v1.22.0
 	github.com/rickb777/servefiles/v3 v3.9.2
+	golang.org/x/crypto v0.36.0
 )
 
 require (
@@ -0,0 +1,90 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+)
+
+// BurnUnit is what burn charts count.
+type BurnUnit string
+
+const (
+	BurnUnitPoints  BurnUnit = "points"
+	BurnUnitTickets BurnUnit = "tickets"
+)
+
+// ParseBurnUnit returns the matching unit, falling back to points.
+func ParseBurnUnit(s string) BurnUnit {
+	if BurnUnit(s) == BurnUnitTickets {
+		return BurnUnitTickets
+	}
+	return BurnUnitPoints
+}
+
+// MaxBurnDays caps how many days a burn chart can cover.
+const MaxBurnDays = 366
+
+// BurnPoint is the state of the board at the end of a day.
+type BurnPoint struct {
+	Day time.Time
+	// Scope is the total work on the board.
+	Scope float64
+	// Done is the work that was done.
+	Done float64
+}
+
+// Remaining is the work left to do at the end of the day.
+func (p BurnPoint) Remaining() float64 {
+	return p.Scope - p.Done
+}
+
+// ComputeBurn replays the ticket history to find the scope and the completed
+// work at the end of every day between from and to, both inclusive. Points
+// after now are left out as they have not happened yet.
+func ComputeBurn(tickets []models.Ticket, from, to, now time.Time, unit BurnUnit) []BurnPoint {
+	from = startOfDay(from)
+	to = startOfDay(to)
+
+	points := []BurnPoint{}
+	for day := from; !day.After(to) && len(points) < MaxBurnDays; day = day.AddDate(0, 0, 1) {
+		if day.After(now) {
+			break
+		}
+
+		end := day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		if end.After(now) {
+			end = now
+		}
+		point := BurnPoint{Day: day}
+
+		for _, t := range tickets {
+			status, existed := t.StatusAt(end)
+			if !existed {
+				continue
+			}
+
+			w := weight(t, unit)
+			point.Scope += w
+			if status == models.StatusDone {
+				point.Done += w
+			}
+		}
+
+		points = append(points, point)
+	}
+
+	return points
+}
+
+func weight(t models.Ticket, unit BurnUnit) float64 {
+	if unit == BurnUnitTickets {
+		return 1
+	}
+	return float64(t.Estimate)
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
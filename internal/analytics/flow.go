@@ -0,0 +1,105 @@
+package analytics
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+)
+
+// TicketFlow holds how long a single completed ticket took.
+type TicketFlow struct {
+	Ticket      models.Ticket
+	CompletedAt time.Time
+	// LeadTime is the time from the ticket being created to it being done.
+	LeadTime time.Duration
+	// CycleTime is the time from work starting on the ticket to it being
+	// done, it is only set when HasCycleTime is true.
+	CycleTime    time.Duration
+	HasCycleTime bool
+}
+
+// Percentiles of a set of durations.
+type Percentiles struct {
+	P50 time.Duration
+	P85 time.Duration
+	P95 time.Duration
+}
+
+// FlowReport summarises lead and cycle times of the tickets completed in a
+// window.
+type FlowReport struct {
+	From      time.Time
+	To        time.Time
+	Tickets   []TicketFlow
+	LeadTime  Percentiles
+	CycleTime Percentiles
+}
+
+// ComputeFlow calculates lead and cycle times for the tickets completed in
+// the window [from, to] using their status transitions. Tickets are ordered
+// by completion time.
+func ComputeFlow(tickets []models.Ticket, from, to time.Time) FlowReport {
+	report := FlowReport{From: from, To: to}
+
+	leadTimes := []time.Duration{}
+	cycleTimes := []time.Duration{}
+
+	for _, t := range tickets {
+		completedAt, ok := t.CompletedAt()
+		if !ok || completedAt.Before(from) || completedAt.After(to) {
+			continue
+		}
+
+		flow := TicketFlow{
+			Ticket:      t,
+			CompletedAt: completedAt,
+			LeadTime:    nonNegative(completedAt.Sub(t.CreatedAt)),
+		}
+		leadTimes = append(leadTimes, flow.LeadTime)
+
+		if startedAt, ok := t.StartedAt(); ok && !startedAt.After(completedAt) {
+			flow.CycleTime = completedAt.Sub(startedAt)
+			flow.HasCycleTime = true
+			cycleTimes = append(cycleTimes, flow.CycleTime)
+		}
+
+		report.Tickets = append(report.Tickets, flow)
+	}
+
+	slices.SortFunc(report.Tickets, func(a, b TicketFlow) int {
+		return a.CompletedAt.Compare(b.CompletedAt)
+	})
+
+	report.LeadTime = percentiles(leadTimes)
+	report.CycleTime = percentiles(cycleTimes)
+
+	return report
+}
+
+func percentiles(durations []time.Duration) Percentiles {
+	slices.Sort(durations)
+	return Percentiles{
+		P50: Percentile(durations, 50),
+		P85: Percentile(durations, 85),
+		P95: Percentile(durations, 95),
+	}
+}
+
+// Percentile returns the p-th percentile of the sorted durations using the
+// nearest rank method, or zero when there are none.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	rank = max(rank, 1)
+	rank = min(rank, len(sorted))
+	return sorted[rank-1]
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	return max(d, 0)
+}
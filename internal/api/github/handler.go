@@ -0,0 +1,260 @@
+package github
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/db"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/util"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/router"
+	"github.com/google/uuid"
+)
+
+const (
+	// SignatureHeader holds the HMAC-SHA256 GitHub signs the body with, keyed
+	// with the secret of the hook, as "sha256=<hex>".
+	SignatureHeader = "X-Hub-Signature-256"
+	// EventHeader names the kind of event GitHub is posting.
+	EventHeader = "X-GitHub-Event"
+	// DeliveryHeader holds the id GitHub gave the delivery.
+	DeliveryHeader = "X-GitHub-Delivery"
+
+	// maxBodyBytes caps the size of an event, issue events are far below it.
+	maxBodyBytes = 1 << 20
+)
+
+type dbClient interface {
+	GetGitHubHook(ctx context.Context, id string) (models.GitHubHook, error)
+	SyncExternalTicket(ctx context.Context, boardId string, incoming models.Ticket, move bool) (models.Ticket, models.TicketEvent, error)
+}
+
+type notifier interface {
+	Notify(userID string, m util.UiMessage)
+}
+
+// NewHandler serves the endpoints GitHub posts issue events to. Requests are
+// authenticated by their signature rather than a session, opened issues are
+// added as todo tickets to the board of the hook and closing or reopening them
+// moves the tickets along.
+func NewHandler(log *slog.Logger, db dbClient, nh notifier) http.Handler {
+	h := &handler{
+		log: log,
+		db:  db,
+		nh:  nh,
+		now: time.Now,
+	}
+
+	h.router = router.New(http.HandlerFunc(h.notFound), http.HandlerFunc(h.methodNotAllowed))
+	h.router.HandleFunc("POST /hooks/github/{id}", h.post)
+
+	return h
+}
+
+type handler struct {
+	log    *slog.Logger
+	db     dbClient
+	nh     notifier
+	now    func() time.Time
+	router *router.Router
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *handler) notFound(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "Not found", http.StatusNotFound)
+}
+
+func (h *handler) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+// issuesEvent is the part of the payload of an issues event which is mirrored
+// on the board.
+type issuesEvent struct {
+	Action string `json:"action"`
+	Issue  struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+		Labels  []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	} `json:"issue"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// syncResponse tells GitHub, and whoever reads its delivery log, what came of
+// the event.
+type syncResponse struct {
+	Ticket string             `json:"ticket,omitempty"`
+	Result models.TicketEvent `json:"result,omitempty"`
+}
+
+func (h *handler) post(w http.ResponseWriter, r *http.Request) {
+	hookId := r.PathValue("id")
+	hook, err := h.db.GetGitHubHook(r.Context(), hookId)
+	if errors.Is(err, db.ErrGitHubHookNotFound) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.log.Error("Error fetching GitHub hook", "hookId", hookId, "error", err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Payload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Error reading body", http.StatusBadRequest)
+		return
+	}
+
+	if !validSignature(hook.Secret, body, r.Header.Get(SignatureHeader)) {
+		h.log.Warn("Invalid GitHub signature", "hookId", hookId, "delivery", r.Header.Get(DeliveryHeader))
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Header.Get(EventHeader) {
+	case "ping":
+		// Sent once when the hook is set up on GitHub.
+		w.WriteHeader(http.StatusNoContent)
+	case "issues":
+		h.issues(w, r, hook, body)
+	default:
+		// Accepted so GitHub does not show the delivery as failed, only
+		// issues are mirrored.
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// issues mirrors an opened, closed or reopened issue on the board of the hook.
+func (h *handler) issues(w http.ResponseWriter, r *http.Request, hook models.GitHubHook, body []byte) {
+	var ev issuesEvent
+	if err := json.Unmarshal(body, &ev); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	if ev.Repository.FullName == "" || ev.Issue.Number == 0 {
+		http.Error(w, "Payload is missing the issue or repository", http.StatusBadRequest)
+		return
+	}
+
+	var status models.Status
+	move := true
+	switch ev.Action {
+	case "opened":
+		// Redelivering an opened event must not undo a later close.
+		status, move = models.StatusTodo, false
+	case "reopened":
+		status = models.StatusTodo
+	case "closed":
+		status = models.StatusDone
+	default:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	incoming := issueTicket(ev, status, h.now())
+	if err := incoming.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	ticket, result, err := h.db.SyncExternalTicket(r.Context(), hook.BoardId, incoming, move)
+	if err != nil {
+		h.log.Error("Error syncing GitHub issue", "hookId", hook.Id, "ref", incoming.ExternalRef, "error", err.Error())
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	issue := fmt.Sprintf("%s#%d", ev.Repository.FullName, ev.Issue.Number)
+	switch result {
+	case models.TicketCreated:
+		h.nh.Notify(hook.BoardId, util.Info(fmt.Sprintf("Created %s from GitHub issue %s", ticket.Key, issue)).
+			WithAction("Open", "/todos/"+ticket.Key))
+	case models.TicketMoved:
+		h.nh.Notify(hook.BoardId, util.Info(fmt.Sprintf("Moved %s to %s, GitHub issue %s was %s", ticket.Key, ticket.Status, issue, ev.Action)).
+			WithAction("Open", "/todos/"+ticket.Key))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(syncResponse{Ticket: ticket.Key, Result: result})
+}
+
+// issueTicket builds the ticket mirroring the issue of the event.
+func issueTicket(ev issuesEvent, status models.Status, now time.Time) models.Ticket {
+	t := models.Ticket{
+		Id:          uuid.NewString(),
+		Title:       truncate(strings.TrimSpace(ev.Issue.Title), models.MaxTitleLength),
+		ExternalRef: models.GitHubIssueRef(ev.Repository.FullName, ev.Issue.Number),
+		CreatedAt:   now,
+	}
+	t.MoveTo(status, now)
+
+	link := ""
+	if ev.Issue.HTMLURL != "" {
+		link = "\n\n" + ev.Issue.HTMLURL
+	}
+	t.Description = truncate(ev.Issue.Body, models.MaxDescriptionLength-utf8.RuneCountInString(link)) + link
+
+	for _, l := range ev.Issue.Labels {
+		if len(t.Labels) == models.MaxLabels {
+			break
+		}
+		if name := strings.TrimSpace(l.Name); name != "" {
+			t.Labels = append(t.Labels, truncate(name, models.MaxLabelLength))
+		}
+	}
+	return t
+}
+
+// truncate cuts s down to at most n runes.
+func truncate(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// validSignature reports whether header is the signature GitHub makes of the
+// body with the secret.
+func validSignature(secret string, body []byte, header string) bool {
+	sent, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sent)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,527 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/db"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/router"
+	"github.com/alexedwards/scs/v2"
+	"github.com/google/uuid"
+)
+
+type dbClient interface {
+	AddToUser(ctx context.Context, id string, ticket models.Ticket) (models.Ticket, error)
+	DeleteTodoByUserAndTodoId(ctx context.Context, userId string, todoId string) (models.Ticket, error)
+	GetBoard(ctx context.Context, id string) (models.Board, error)
+	GetTicketByUser(ctx context.Context, userId string, ref string) (models.Ticket, error)
+	UpdateBoard(ctx context.Context, board models.Board) error
+	UpdateTicket(ctx context.Context, userId string, ticket models.Ticket) error
+}
+
+const (
+	defaultLimit = 50
+	maxLimit     = 200
+	maxBodyBytes = 64 << 10
+)
+
+// NewHandler serves the versioned JSON API. Requests are authenticated with
+// the session cookie and every user can only see their own board.
+func NewHandler(log *slog.Logger, db dbClient, sm *scs.SessionManager) http.Handler {
+	h := &handler{
+		log: log,
+		db:  db,
+		sm:  sm,
+	}
+
+	h.router = router.New(http.HandlerFunc(h.notFound), http.HandlerFunc(h.methodNotAllowed))
+	for _, rt := range h.routes() {
+		h.router.HandleFunc(rt.method+" "+rt.path, rt.handler)
+	}
+	h.openAPI = buildOpenAPI(h.routes())
+	h.router.HandleFunc("GET /api/v1/openapi.json", h.getOpenAPI)
+
+	return h
+}
+
+type handler struct {
+	log     *slog.Logger
+	db      dbClient
+	sm      *scs.SessionManager
+	router  *router.Router
+	openAPI map[string]any
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+// routes describes every endpoint, it is used both to register the handlers
+// and to generate the OpenAPI document so the two cannot drift apart.
+func (h *handler) routes() []route {
+	ticketFilters := []queryParam{
+		{Name: "status", Description: "Only tickets with this status", Enum: []string{"todo", "in-progress", "done"}},
+		{Name: "label", Description: "Only tickets with this label"},
+		{Name: "priority", Description: "Only tickets with this priority, none for tickets without one", Enum: []string{"none", "low", "medium", "high", "urgent"}},
+		{Name: "assignee", Description: "Only tickets assigned to this person"},
+		{Name: "q", Description: "Free text search over key, title, description and labels"},
+		{Name: "archived", Description: "List archived tickets instead of the ones on the board", Enum: []string{"true", "false"}},
+		{Name: "sort", Description: "Order of the tickets, board order when not set", Enum: []string{"updated", "created", "priority"}},
+	}
+
+	return []route{
+		{
+			method:      http.MethodGet,
+			path:        "/api/v1/boards",
+			operationId: "listBoards",
+			summary:     "List the boards of the current user",
+			query:       paginationParams,
+			response:    boardResponse{},
+			list:        true,
+			handler:     h.listBoards,
+		},
+		{
+			method:      http.MethodGet,
+			path:        "/api/v1/boards/{boardId}",
+			operationId: "getBoard",
+			summary:     "Get a board",
+			response:    boardResponse{},
+			handler:     h.getBoard,
+		},
+		{
+			method:      http.MethodGet,
+			path:        "/api/v1/boards/{boardId}/tickets",
+			operationId: "listTickets",
+			summary:     "List the tickets of a board",
+			query:       append(ticketFilters, paginationParams...),
+			response:    ticketResponse{},
+			list:        true,
+			handler:     h.listTickets,
+		},
+		{
+			method:      http.MethodPost,
+			path:        "/api/v1/boards/{boardId}/tickets",
+			operationId: "createTicket",
+			summary:     "Create a ticket",
+			request:     createTicketRequest{},
+			response:    ticketResponse{},
+			status:      http.StatusCreated,
+			handler:     h.createTicket,
+		},
+		{
+			method:      http.MethodGet,
+			path:        "/api/v1/boards/{boardId}/tickets/{ticketId}",
+			operationId: "getTicket",
+			summary:     "Get a ticket by id or key",
+			response:    ticketResponse{},
+			handler:     h.getTicket,
+		},
+		{
+			method:      http.MethodPatch,
+			path:        "/api/v1/boards/{boardId}/tickets/{ticketId}",
+			operationId: "updateTicket",
+			summary:     "Update the supplied fields of a ticket",
+			request:     updateTicketRequest{},
+			response:    ticketResponse{},
+			handler:     h.updateTicket,
+		},
+		{
+			method:      http.MethodPost,
+			path:        "/api/v1/boards/{boardId}/tickets/{ticketId}/move",
+			operationId: "moveTicket",
+			summary:     "Move a ticket to another status, lane or position",
+			request:     moveTicketRequest{},
+			response:    ticketResponse{},
+			handler:     h.moveTicket,
+		},
+		{
+			method:      http.MethodDelete,
+			path:        "/api/v1/boards/{boardId}/tickets/{ticketId}",
+			operationId: "deleteTicket",
+			summary:     "Delete a ticket",
+			status:      http.StatusNoContent,
+			handler:     h.deleteTicket,
+		},
+	}
+}
+
+var paginationParams = []queryParam{
+	{Name: "limit", Description: fmt.Sprintf("Page size, defaults to %d and at most %d", defaultLimit, maxLimit), Integer: true},
+	{Name: "offset", Description: "Number of items to skip", Integer: true},
+}
+
+func (h *handler) notFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, codeNotFound, "No such endpoint")
+}
+
+func (h *handler) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed,
+		fmt.Sprintf("Method %s is not allowed, allowed: %s", r.Method, w.Header().Get("Allow")))
+}
+
+func (h *handler) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.openAPI)
+}
+
+// board loads the board in the path, making sure it belongs to the user of
+// the session. It writes the error response and returns false otherwise.
+func (h *handler) board(w http.ResponseWriter, r *http.Request) (models.Board, bool) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "No active session")
+		return models.Board{}, false
+	}
+
+	if r.PathValue("boardId") != userId {
+		writeError(w, http.StatusNotFound, codeNotFound, "Board not found")
+		return models.Board{}, false
+	}
+
+	board, err := h.db.GetBoard(r.Context(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, codeNotFound, "Board not found")
+		return models.Board{}, false
+	}
+	if err != nil {
+		h.internalError(w, "Error fetching board", err)
+		return models.Board{}, false
+	}
+
+	return board, true
+}
+
+// ticket loads the ticket in the path from the board.
+func (h *handler) ticket(w http.ResponseWriter, r *http.Request, board models.Board) (models.Ticket, bool) {
+	ticket, err := h.db.GetTicketByUser(r.Context(), board.Id, r.PathValue("ticketId"))
+	if errors.Is(err, db.ErrTicketNotFound) {
+		writeError(w, http.StatusNotFound, codeNotFound, "Ticket not found")
+		return models.Ticket{}, false
+	}
+	if err != nil {
+		h.internalError(w, "Error fetching ticket", err)
+		return models.Ticket{}, false
+	}
+	return ticket, true
+}
+
+// internalError responds to an error of the database. Conflicting writes are
+// the client's to retry, anything else is logged.
+func (h *handler) internalError(w http.ResponseWriter, msg string, err error) {
+	if errors.Is(err, db.ErrConflict) {
+		writeError(w, http.StatusConflict, codeConflict, "The board was changed by another request, try again")
+		return
+	}
+
+	h.log.Error(msg, "error", err.Error())
+	writeError(w, http.StatusInternalServerError, codeInternalError, msg)
+}
+
+// decodeBody decodes the JSON request body into v, rejecting unknown fields.
+// The API is served without CSRF tokens, requiring the JSON content type is
+// what keeps other sites from posting to it with the session cookie, as
+// browsers only send it cross site after a CORS preflight, which is never
+// allowed.
+func (h *handler) decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, codeUnsupportedMedia, "Content-Type must be application/json")
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err = dec.Decode(v)
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		writeError(w, http.StatusRequestEntityTooLarge, codeRequestTooLarge, "Request body is too large")
+		return false
+	case err != nil:
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// pagination parses the limit and offset query parameters.
+func pagination(w http.ResponseWriter, r *http.Request) (limit, offset int, ok bool) {
+	limit, offset = defaultLimit, 0
+	var err error
+
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxLimit {
+			writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxLimit))
+			return 0, 0, false
+		}
+	}
+
+	if s := r.URL.Query().Get("offset"); s != "" {
+		offset, err = strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "offset must not be negative")
+			return 0, 0, false
+		}
+	}
+
+	return limit, offset, true
+}
+
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	return items[offset:min(offset+limit, len(items))]
+}
+
+func (h *handler) listBoards(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "No active session")
+		return
+	}
+
+	limit, offset, ok := pagination(w, r)
+	if !ok {
+		return
+	}
+
+	board, err := h.db.GetBoard(r.Context(), userId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.internalError(w, "Error fetching board", err)
+		return
+	}
+
+	boards := []boardResponse{}
+	if err == nil {
+		boards = append(boards, newBoardResponse(board))
+	}
+
+	writeList(w, page(boards, limit, offset), paginationResponse{
+		Limit:  limit,
+		Offset: offset,
+		Total:  len(boards),
+	})
+}
+
+func (h *handler) getBoard(w http.ResponseWriter, r *http.Request) {
+	board, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+
+	writeData(w, http.StatusOK, newBoardResponse(board))
+}
+
+func (h *handler) listTickets(w http.ResponseWriter, r *http.Request) {
+	board, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, ok := pagination(w, r)
+	if !ok {
+		return
+	}
+
+	filter := models.ParseTicketFilter(r.URL.Query())
+	filter.Archived = r.URL.Query().Get("archived") == "true"
+	sort := models.ParseTicketSort(r.URL.Query().Get("sort"))
+	tickets := models.SortTickets(models.FilterTickets(board.Tickets, filter), sort)
+
+	res := []ticketResponse{}
+	for _, t := range page(tickets, limit, offset) {
+		res = append(res, newTicketResponse(t))
+	}
+
+	writeList(w, res, paginationResponse{
+		Limit:  limit,
+		Offset: offset,
+		Total:  len(tickets),
+	})
+}
+
+func (h *handler) createTicket(w http.ResponseWriter, r *http.Request) {
+	board, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+
+	req := createTicketRequest{}
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	status := models.Status(req.Status)
+	if status == "" {
+		status = models.StatusTodo
+	}
+
+	labels := models.ParseLabels(strings.Join(req.Labels, ","))
+
+	dueAt, err := models.ParseDueDate(req.DueDate)
+	if err != nil {
+		writeValidationError(w, models.ValidationErrors{"due_date": dueDateError})
+		return
+	}
+
+	now := time.Now()
+	ticket := models.Ticket{
+		Id:          uuid.NewString(),
+		Title:       strings.TrimSpace(req.Title),
+		Description: req.Description,
+		Labels:      labels,
+		Priority:    models.Priority(req.Priority),
+		Assignee:    strings.TrimSpace(req.Assignee),
+		Estimate:    req.Estimate,
+		DueAt:       dueAt,
+		CreatedAt:   now,
+	}
+	ticket.MoveTo(status, now)
+
+	var validationErrs models.ValidationErrors
+	if errors.As(ticket.Validate(), &validationErrs) {
+		writeValidationError(w, validationErrs)
+		return
+	}
+
+	ticket, err = h.db.AddToUser(r.Context(), board.Id, ticket)
+	if err != nil {
+		h.internalError(w, "Error adding ticket", err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/boards/%s/tickets/%s", board.Id, ticket.Id))
+	writeData(w, http.StatusCreated, newTicketResponse(ticket))
+}
+
+func (h *handler) getTicket(w http.ResponseWriter, r *http.Request) {
+	board, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+
+	ticket, ok := h.ticket(w, r, board)
+	if !ok {
+		return
+	}
+
+	writeData(w, http.StatusOK, newTicketResponse(ticket))
+}
+
+func (h *handler) updateTicket(w http.ResponseWriter, r *http.Request) {
+	board, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+
+	ticket, ok := h.ticket(w, r, board)
+	if !ok {
+		return
+	}
+
+	req := updateTicketRequest{}
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		req.Title = &title
+	}
+	if req.Labels != nil {
+		labels := models.ParseLabels(strings.Join(*req.Labels, ","))
+		req.Labels = &labels
+	}
+
+	patch, err := req.toPatch()
+	if err == nil {
+		patch.Apply(&ticket, time.Now())
+		err = ticket.Validate()
+	}
+
+	var validationErrs models.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		writeValidationError(w, validationErrs)
+		return
+	}
+
+	err = h.db.UpdateTicket(r.Context(), board.Id, ticket)
+	if err != nil {
+		h.internalError(w, "Error updating ticket", err)
+		return
+	}
+
+	writeData(w, http.StatusOK, newTicketResponse(ticket))
+}
+
+func (h *handler) moveTicket(w http.ResponseWriter, r *http.Request) {
+	board, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+
+	ticket, ok := h.ticket(w, r, board)
+	if !ok {
+		return
+	}
+
+	req := moveTicketRequest{}
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	swimlanes := models.ParseSwimlaneField(req.Swimlanes)
+	tickets, moved, ok := models.MoveTicket(board.Tickets, ticket.Id, models.Status(req.Status), swimlanes, req.Lane, req.Index, time.Now())
+	if !ok {
+		writeError(w, http.StatusNotFound, codeNotFound, "Ticket not found")
+		return
+	}
+
+	var validationErrs models.ValidationErrors
+	if errors.As(moved.Validate(), &validationErrs) {
+		writeValidationError(w, validationErrs)
+		return
+	}
+
+	board.Tickets = tickets
+	err := h.db.UpdateBoard(r.Context(), board)
+	if err != nil {
+		h.internalError(w, "Error moving ticket", err)
+		return
+	}
+
+	writeData(w, http.StatusOK, newTicketResponse(moved))
+}
+
+func (h *handler) deleteTicket(w http.ResponseWriter, r *http.Request) {
+	board, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+
+	ticket, ok := h.ticket(w, r, board)
+	if !ok {
+		return
+	}
+
+	_, err := h.db.DeleteTodoByUserAndTodoId(r.Context(), board.Id, ticket.Id)
+	if err != nil {
+		h.internalError(w, "Error deleting ticket", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,239 @@
+package v1
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// route is a single API endpoint along with what is needed to describe it
+// in the OpenAPI document.
+type route struct {
+	method      string
+	path        string
+	operationId string
+	summary     string
+	query       []queryParam
+	// request and response are zero values of the body types, nil when the
+	// endpoint has no body.
+	request  any
+	response any
+	// list wraps the response in the paginated list envelope.
+	list    bool
+	status  int
+	handler http.HandlerFunc
+}
+
+type queryParam struct {
+	Name        string
+	Description string
+	Enum        []string
+	Integer     bool
+}
+
+var pathParamRegex = regexp.MustCompile(`{(\w+)}`)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// buildOpenAPI generates an OpenAPI 3.1 document from the route table. Body
+// schemas are generated from the Go types via reflection, using the json
+// tags for names and the doc, enum and required tags for the rest.
+func buildOpenAPI(routes []route) map[string]any {
+	s := &schemaBuilder{components: map[string]any{}}
+
+	errorRef := s.schema(reflect.TypeOf(errorResponse{}))
+	paths := map[string]map[string]any{}
+
+	for _, rt := range routes {
+		params := []any{}
+		for _, m := range pathParamRegex.FindAllStringSubmatch(rt.path, -1) {
+			params = append(params, map[string]any{
+				"name":     m[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+		for _, q := range rt.query {
+			schema := map[string]any{"type": "string"}
+			if q.Integer {
+				schema["type"] = "integer"
+			}
+			if len(q.Enum) > 0 {
+				schema["enum"] = q.Enum
+			}
+			params = append(params, map[string]any{
+				"name":        q.Name,
+				"in":          "query",
+				"description": q.Description,
+				"schema":      schema,
+			})
+		}
+
+		status := rt.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		success := map[string]any{"description": http.StatusText(status)}
+		if rt.response != nil {
+			data := s.schema(reflect.TypeOf(rt.response))
+			envelope := map[string]any{
+				"type":       "object",
+				"required":   []string{"data"},
+				"properties": map[string]any{"data": data},
+			}
+			if rt.list {
+				envelope["required"] = []string{"data", "pagination"}
+				envelope["properties"] = map[string]any{
+					"data":       map[string]any{"type": "array", "items": data},
+					"pagination": s.schema(reflect.TypeOf(paginationResponse{})),
+				}
+			}
+			success["content"] = map[string]any{
+				"application/json": map[string]any{"schema": envelope},
+			}
+		}
+
+		op := map[string]any{
+			"operationId": rt.operationId,
+			"summary":     rt.summary,
+			"parameters":  params,
+			"responses": map[string]any{
+				strconv.Itoa(status): success,
+				"default": map[string]any{
+					"description": "Error",
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": errorRef},
+					},
+				},
+			},
+		}
+		if rt.request != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": s.schema(reflect.TypeOf(rt.request))},
+				},
+			}
+		}
+
+		if paths[rt.path] == nil {
+			paths[rt.path] = map[string]any{}
+		}
+		paths[rt.path][strings.ToLower(rt.method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Lambdaban API",
+			"version":     "1",
+			"description": "Authenticated with the session cookie of the web app. Request bodies must be sent as application/json.",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": s.components},
+	}
+}
+
+type schemaBuilder struct {
+	components map[string]any
+}
+
+// schema returns the schema of t. Named structs are added to the components
+// and referenced.
+func (s *schemaBuilder) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Pointer:
+		schema := s.schema(t.Elem())
+		if typ, ok := schema["type"]; ok {
+			schema["type"] = []any{typ, "null"}
+		}
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		name := componentName(t)
+		if _, ok := s.components[name]; !ok {
+			// Reserve the name first so recursive types terminate.
+			s.components[name] = nil
+			s.components[name] = s.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+
+	return map[string]any{}
+}
+
+func (s *schemaBuilder) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		schema := s.schema(f.Type)
+		if doc := f.Tag.Get("doc"); doc != "" {
+			schema["description"] = doc
+		}
+		if enum, ok := f.Tag.Lookup("enum"); ok {
+			schema["enum"] = strings.Split(enum, ",")
+		}
+		properties[name] = schema
+
+		if f.Tag.Get("required") == "true" || (f.Type.Kind() != reflect.Pointer && !strings.Contains(opts, "omitempty") && isResponse(t)) {
+			required = append(required, name)
+		}
+	}
+
+	obj := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
+
+// isResponse reports whether t is only ever sent by the server, in which
+// case every field without omitempty is always present.
+func isResponse(t reflect.Type) bool {
+	return !strings.HasSuffix(t.Name(), "Request")
+}
+
+// componentName turns a type name like ticketResponse into Ticket.
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	name = strings.TrimSuffix(name, "Response")
+	if name == "" {
+		return t.String()
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
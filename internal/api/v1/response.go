@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	codeBadRequest       = "bad_request"
+	codeValidationFailed = "validation_failed"
+	codeUnauthorized     = "unauthorized"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeConflict         = "conflict"
+	codeRequestTooLarge  = "request_too_large"
+	codeUnsupportedMedia = "unsupported_media_type"
+	codeInternalError    = "internal_error"
+)
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeData wraps a single resource in the data envelope.
+func writeData(w http.ResponseWriter, status int, v any) {
+	writeJSON(w, status, map[string]any{"data": v})
+}
+
+// writeList wraps a page of resources in the data envelope along with the
+// pagination details.
+func writeList(w http.ResponseWriter, v any, p paginationResponse) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data":       v,
+		"pagination": p,
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorResponse{
+		Error: errorBody{Code: code, Message: message},
+	})
+}
+
+func writeValidationError(w http.ResponseWriter, fields map[string]string) {
+	writeJSON(w, http.StatusBadRequest, errorResponse{
+		Error: errorBody{
+			Code:    codeValidationFailed,
+			Message: "One or more fields are invalid",
+			Fields:  fields,
+		},
+	})
+}
@@ -0,0 +1,162 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+)
+
+type boardResponse struct {
+	Id           string         `json:"id" doc:"Id of the board, the same as the id of the user owning it"`
+	KeyPrefix    string         `json:"key_prefix" doc:"Prefix of the ticket keys, e.g. LB in LB-42"`
+	TicketCounts map[string]int `json:"ticket_counts" doc:"Number of tickets per status"`
+}
+
+func newBoardResponse(b models.Board) boardResponse {
+	counts := map[string]int{}
+	for _, s := range models.Statuses {
+		counts[s.String()] = 0
+	}
+	for _, t := range b.Tickets {
+		counts[t.Status.String()]++
+	}
+
+	return boardResponse{
+		Id:           b.Id,
+		KeyPrefix:    b.KeyPrefix,
+		TicketCounts: counts,
+	}
+}
+
+type ticketResponse struct {
+	Id          string               `json:"id"`
+	Key         string               `json:"key" doc:"Human readable key, e.g. LB-42"`
+	Title       string               `json:"title"`
+	Description string               `json:"description"`
+	Status      string               `json:"status" enum:"todo,in-progress,done"`
+	Labels      []string             `json:"labels"`
+	Priority    string               `json:"priority" enum:",low,medium,high,urgent"`
+	Assignee    string               `json:"assignee"`
+	Estimate    int                  `json:"estimate" doc:"Story points, zero when not estimated"`
+	DueDate     string               `json:"due_date" doc:"Day the ticket is due as YYYY-MM-DD, empty when it has no due date"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	ArchivedAt  *time.Time           `json:"archived_at" doc:"When the ticket was taken off the board, null while it is on it"`
+	Transitions []transitionResponse `json:"transitions"`
+}
+
+type transitionResponse struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+func newTicketResponse(t models.Ticket) ticketResponse {
+	res := ticketResponse{
+		Id:          t.Id,
+		Key:         t.Key,
+		Title:       t.Title,
+		Description: t.Description,
+		Status:      t.Status.String(),
+		Labels:      t.Labels,
+		Priority:    t.Priority.String(),
+		Assignee:    t.Assignee,
+		Estimate:    t.Estimate,
+		DueDate:     t.DueDate(),
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.LastUpdatedAt,
+		ArchivedAt:  t.ArchivedAt,
+		Transitions: make([]transitionResponse, 0, len(t.Transitions)),
+	}
+	if res.Labels == nil {
+		res.Labels = []string{}
+	}
+	for _, tr := range t.Transitions {
+		res.Transitions = append(res.Transitions, transitionResponse{
+			From: tr.From.String(),
+			To:   tr.To.String(),
+			At:   tr.At,
+		})
+	}
+	return res
+}
+
+type createTicketRequest struct {
+	Title       string   `json:"title" required:"true"`
+	Description string   `json:"description"`
+	Status      string   `json:"status" enum:"todo,in-progress,done" doc:"Defaults to todo"`
+	Labels      []string `json:"labels"`
+	Priority    string   `json:"priority" enum:",low,medium,high,urgent"`
+	Assignee    string   `json:"assignee"`
+	Estimate    int      `json:"estimate"`
+	DueDate     string   `json:"due_date" doc:"Day the ticket is due as YYYY-MM-DD"`
+}
+
+// updateTicketRequest only updates the fields which are present.
+type updateTicketRequest struct {
+	Title       *string   `json:"title"`
+	Description *string   `json:"description"`
+	Status      *string   `json:"status" enum:"todo,in-progress,done"`
+	Labels      *[]string `json:"labels"`
+	Priority    *string   `json:"priority" enum:",low,medium,high,urgent"`
+	Assignee    *string   `json:"assignee"`
+	Estimate    *int      `json:"estimate"`
+	DueDate     *string   `json:"due_date" doc:"Day the ticket is due as YYYY-MM-DD, empty to clear it"`
+}
+
+// dueDateError describes a due date which could not be parsed.
+const dueDateError = "Due date must be a date like 2006-01-02"
+
+// toPatch turns the request into a patch, it returns ValidationErrors when a
+// field cannot be parsed.
+func (u updateTicketRequest) toPatch() (models.TicketPatch, error) {
+	patch := models.TicketPatch{
+		Title:       u.Title,
+		Description: u.Description,
+		Labels:      u.Labels,
+		Assignee:    u.Assignee,
+		Estimate:    u.Estimate,
+	}
+	if u.Status != nil {
+		status := models.Status(*u.Status)
+		patch.Status = &status
+	}
+	if u.Priority != nil {
+		priority := models.Priority(*u.Priority)
+		patch.Priority = &priority
+	}
+	if u.DueDate != nil {
+		dueAt, err := models.ParseDueDate(*u.DueDate)
+		if err != nil {
+			return models.TicketPatch{}, models.ValidationErrors{"due_date": dueDateError}
+		}
+		if dueAt == nil {
+			dueAt = &time.Time{}
+		}
+		patch.DueAt = dueAt
+	}
+	return patch, nil
+}
+
+type moveTicketRequest struct {
+	Status    string `json:"status" required:"true" enum:"todo,in-progress,done"`
+	Swimlanes string `json:"swimlanes" enum:",label,priority,assignee" doc:"Field the board is grouped by, lane is only used when set"`
+	Lane      string `json:"lane" doc:"Lane to move the ticket into"`
+	Index     int    `json:"index" doc:"Position among the tickets of the destination, defaults to the top"`
+}
+
+type paginationResponse struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	Total  int `json:"total"`
+}
+
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code    string            `json:"code" enum:"bad_request,validation_failed,unauthorized,not_found,method_not_allowed,conflict,request_too_large,internal_error"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty" doc:"Field level validation errors"`
+}
@@ -0,0 +1,70 @@
+// Package atom writes Atom (RFC 4287) feeds.
+package atom
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// ContentType is the media type of Atom feeds.
+const ContentType = "application/atom+xml; charset=utf-8"
+
+const namespace = "http://www.w3.org/2005/Atom"
+
+// Feed is an Atom feed document.
+type Feed struct {
+	XMLName xml.Name `xml:"feed"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	// Id identifies the feed across every version of it, it must never
+	// change.
+	Id      string  `xml:"id"`
+	Title   string  `xml:"title"`
+	Updated Time    `xml:"updated"`
+	Author  Person  `xml:"author"`
+	Links   []Link  `xml:"link"`
+	Entries []Entry `xml:"entry"`
+}
+
+// Entry is a single entry of a feed.
+type Entry struct {
+	// Id identifies the entry across every version of the feed.
+	Id      string `xml:"id"`
+	Title   string `xml:"title"`
+	Updated Time   `xml:"updated"`
+	Links   []Link `xml:"link,omitempty"`
+	Summary string `xml:"summary,omitempty"`
+}
+
+// Link is a reference from a feed or entry to a web resource.
+type Link struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// Person is the author of a feed.
+type Person struct {
+	Name string `xml:"name"`
+}
+
+// Time is a timestamp written as an RFC 3339 date-time in UTC.
+type Time time.Time
+
+func (t Time) MarshalText() ([]byte, error) {
+	return []byte(time.Time(t).UTC().Format(time.RFC3339)), nil
+}
+
+// Write writes the feed to w as an XML document.
+func (f Feed) Write(w io.Writer) error {
+	f.Xmlns = namespace
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,27 @@
+// Package auth hashes and checks the passwords of accounts.
+package auth
+
+import "golang.org/x/crypto/bcrypt"
+
+// dummyHash is checked against when there is no account to check a password
+// against, so logging in takes as long whether the account exists or not.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("lambdaban"), bcrypt.DefaultCost)
+
+// HashPassword returns the bcrypt hash of the password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether the password matches the hash. An empty hash
+// never matches, it takes as long as any other to check.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
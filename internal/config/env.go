@@ -1,7 +1,9 @@
 package config
 
 import (
+	"net/url"
 	"os"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -11,6 +13,24 @@ type Config struct {
 	DbPass string
 	DbHost string
 	DbName string
+
+	// ShareSecret signs the tokens of board share links.
+	ShareSecret string
+
+	// BaseURL is the URL the app is reached at, without a trailing slash.
+	// Links handed out, such as share links, are built from it, never from
+	// the request.
+	BaseURL string
+
+	// MailLogOnly logs emails instead of sending them, for development only.
+	// The SMTP settings are required without it.
+	MailLogOnly bool
+	SMTPHost    string
+	SMTPPort    string
+	SMTPUser    string
+	SMTPPass    string
+	// MailFrom is the address emails are sent from.
+	MailFrom string
 }
 
 func GetConfig() Config {
@@ -36,10 +56,51 @@ func GetConfig() Config {
 		panic("DB_NAME not set")
 	}
 
+	shareSecret := os.Getenv("SHARE_SECRET")
+	if shareSecret == "" {
+		panic("SHARE_SECRET not set")
+	}
+
+	baseURL := strings.TrimSuffix(os.Getenv("BASE_URL"), "/")
+	if baseURL == "" {
+		panic("BASE_URL not set")
+	}
+	if u, err := url.Parse(baseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		panic("BASE_URL must be an absolute http or https URL")
+	}
+
+	mailLogOnly := os.Getenv("MAIL_LOG_ONLY") == "true"
+
+	smtpHost := os.Getenv("SMTP_HOST")
+	if smtpHost == "" && !mailLogOnly {
+		panic("SMTP_HOST not set")
+	}
+
+	smtpPort := os.Getenv("SMTP_PORT")
+	if smtpPort == "" {
+		smtpPort = "587"
+	}
+
+	mailFrom := os.Getenv("MAIL_FROM")
+	if mailFrom == "" && !mailLogOnly {
+		panic("MAIL_FROM not set")
+	}
+
 	return Config{
 		DbUser: user,
 		DbPass: pass,
 		DbHost: host,
 		DbName: name,
+
+		ShareSecret: shareSecret,
+
+		BaseURL: baseURL,
+
+		MailLogOnly: mailLogOnly,
+		SMTPHost:    smtpHost,
+		SMTPPort:    smtpPort,
+		SMTPUser:    os.Getenv("SMTP_USER"),
+		SMTPPass:    os.Getenv("SMTP_PASS"),
+		MailFrom:    mailFrom,
 	}
 }
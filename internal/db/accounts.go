@@ -0,0 +1,251 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+)
+
+var (
+	ErrAccountNotFound       = errors.New("account not found")
+	ErrUsernameTaken         = errors.New("username taken")
+	ErrEmailTaken            = errors.New("email taken")
+	ErrPasswordResetNotFound = errors.New("password reset not found")
+)
+
+// uniqueViolation is the Postgres error code of unique constraint violations.
+const uniqueViolation = "23505"
+
+// CreateAccount registers the account and gives it a board. The board with
+// claimBoardId becomes the account's when no other account owns it, so
+// registering keeps the board the user was working on, a new board is
+// created otherwise. It returns ErrUsernameTaken or ErrEmailTaken when
+// another account has either.
+func (c *Client) CreateAccount(ctx context.Context, account models.Account, claimBoardId string) (models.Account, error) {
+	account.Id = uuid.NewString()
+	account.CreatedAt = c.now()
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Account{}, err
+	}
+	defer tx.Rollback()
+
+	sqlStr, args, err := c.sq.
+		Insert("accounts").
+		Columns("id", "username", "email", "password_hash", "created_at").
+		Values(account.Id, account.Username, account.Email, account.PasswordHash, account.CreatedAt).
+		ToSql()
+	if err != nil {
+		return models.Account{}, err
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			switch pqErr.Constraint {
+			case "accounts_username_key":
+				return models.Account{}, ErrUsernameTaken
+			case "accounts_email_key":
+				return models.Account{}, ErrEmailTaken
+			}
+		}
+		return models.Account{}, err
+	}
+
+	if claimBoardId != "" {
+		sqlStr, args, err := c.sq.
+			Update("users").
+			Set("account_id", account.Id).
+			Where(squirrel.Eq{"id": claimBoardId, "account_id": nil}).
+			Suffix("RETURNING id").
+			ToSql()
+		if err != nil {
+			return models.Account{}, err
+		}
+		err = tx.QueryRowContext(ctx, sqlStr, args...).Scan(&account.BoardId)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return models.Account{}, err
+		}
+	}
+
+	if account.BoardId == "" {
+		account.BoardId, err = c.createBoard(ctx, tx, account.Id)
+		if err != nil {
+			return models.Account{}, err
+		}
+	}
+
+	return account, tx.Commit()
+}
+
+// GetAccountByLogin returns the account with the given username or email,
+// along with the id of its board.
+func (c *Client) GetAccountByLogin(ctx context.Context, login string) (models.Account, error) {
+	login = models.NormalizeLogin(login)
+	sqlStr, args, err := c.sq.
+		Select("a.id", "a.username", "a.email", "a.password_hash", "a.session_generation", "COALESCE(u.id::text, '')", "a.created_at").
+		From("accounts a").
+		LeftJoin("users u ON u.account_id = a.id").
+		Where(squirrel.Or{squirrel.Eq{"a.username": login}, squirrel.Eq{"a.email": login}}).
+		ToSql()
+	if err != nil {
+		return models.Account{}, err
+	}
+	var a models.Account
+	err = c.db.QueryRowContext(ctx, sqlStr, args...).
+		Scan(&a.Id, &a.Username, &a.Email, &a.PasswordHash, &a.SessionGeneration, &a.BoardId, &a.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Account{}, ErrAccountNotFound
+	}
+	return a, err
+}
+
+// GetSessionGeneration returns the session generation of the account, sessions
+// logged in under any other generation have been revoked.
+func (c *Client) GetSessionGeneration(ctx context.Context, accountId string) (int, error) {
+	sqlStr, args, err := c.sq.
+		Select("session_generation").
+		From("accounts").
+		Where(squirrel.Eq{"id": accountId}).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+	var generation int
+	err = c.db.QueryRowContext(ctx, sqlStr, args...).Scan(&generation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrAccountNotFound
+	}
+	return generation, err
+}
+
+// CreateAccountBoard gives the account a new board, for accounts whose board
+// was deleted, and returns its id.
+func (c *Client) CreateAccountBoard(ctx context.Context, accountId string) (string, error) {
+	return c.createBoard(ctx, c.db, accountId)
+}
+
+// hashResetToken returns the hash a password reset token is kept as, so the
+// tokens cannot be used by anyone reading the database.
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreatePasswordReset returns a new token resetting the password of the
+// account, valid for ttl. Expired resets of every account are removed on the
+// way.
+func (c *Client) CreatePasswordReset(ctx context.Context, accountId string, ttl time.Duration) (string, error) {
+	now := c.now()
+
+	sqlStr, args, err := c.sq.
+		Delete("password_resets").
+		Where(squirrel.LtOrEq{"expires_at": now}).
+		ToSql()
+	if err != nil {
+		return "", err
+	}
+	if _, err := c.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return "", err
+	}
+
+	token := rand.Text()
+	sqlStr, args, err = c.sq.
+		Insert("password_resets").
+		Columns("token_hash", "account_id", "expires_at", "created_at").
+		Values(hashResetToken(token), accountId, now.Add(ttl), now).
+		ToSql()
+	if err != nil {
+		return "", err
+	}
+	if _, err := c.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CheckPasswordReset returns ErrPasswordResetNotFound unless the token resets
+// a password and has not expired.
+func (c *Client) CheckPasswordReset(ctx context.Context, token string) error {
+	sqlStr, args, err := c.sq.
+		Select("1").
+		From("password_resets").
+		Where(squirrel.Eq{"token_hash": hashResetToken(token)}).
+		Where(squirrel.Gt{"expires_at": c.now()}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	var one int
+	err = c.db.QueryRowContext(ctx, sqlStr, args...).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrPasswordResetNotFound
+	}
+	return err
+}
+
+// ResetPassword uses up the token to replace the password hash of its
+// account, along with every other pending reset of the account. The session
+// generation of the account goes up, logging out every session. It returns
+// ErrPasswordResetNotFound when the token is unknown or expired.
+func (c *Client) ResetPassword(ctx context.Context, token, passwordHash string) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sqlStr, args, err := c.sq.
+		Delete("password_resets").
+		Where(squirrel.Eq{"token_hash": hashResetToken(token)}).
+		Where(squirrel.Gt{"expires_at": c.now()}).
+		Suffix("RETURNING account_id").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	var accountId string
+	err = tx.QueryRowContext(ctx, sqlStr, args...).Scan(&accountId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrPasswordResetNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	sqlStr, args, err = c.sq.
+		Update("accounts").
+		Set("password_hash", passwordHash).
+		Set("session_generation", squirrel.Expr("session_generation + 1")).
+		Where(squirrel.Eq{"id": accountId}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return err
+	}
+
+	sqlStr, args, err = c.sq.
+		Delete("password_resets").
+		Where(squirrel.Eq{"account_id": accountId}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrCalendarNotFound = errors.New("calendar not found")
+
+// GetCalendarToken returns the token of the calendar feed of the board, or an
+// empty string when it has none.
+func (c *Client) GetCalendarToken(ctx context.Context, boardId string) (string, error) {
+	return c.getBoardToken(ctx, "calendar_token", boardId)
+}
+
+// ResetCalendarToken gives the board a new calendar feed token, the feed is
+// no longer served under the old one.
+func (c *Client) ResetCalendarToken(ctx context.Context, boardId string) (string, error) {
+	return c.resetBoardToken(ctx, "calendar_token", boardId)
+}
+
+// DisableCalendarToken stops serving the calendar feed of the board.
+func (c *Client) DisableCalendarToken(ctx context.Context, boardId string) error {
+	return c.setBoardToken(ctx, "calendar_token", boardId, nil)
+}
+
+// GetCalendarBoardId returns the id of the board the calendar feed token
+// belongs to. It returns ErrCalendarNotFound when no board has it.
+func (c *Client) GetCalendarBoardId(ctx context.Context, token string) (string, error) {
+	return c.boardIdByToken(ctx, "calendar_token", token, ErrCalendarNotFound)
+}
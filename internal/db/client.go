@@ -7,20 +7,30 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 
+	"github.com/JamesTiberiusKirk/lambdaban/internal/events"
 	"github.com/JamesTiberiusKirk/lambdaban/internal/metrics"
 	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
 	"github.com/JamesTiberiusKirk/migrator/migrator"
 )
 
+var (
+	ErrTicketNotFound = errors.New("ticket not found")
+	// ErrConflict is returned when the board was changed by another request
+	// between it being read and written back.
+	ErrConflict = errors.New("board was changed concurrently")
+)
+
 type Client struct {
 	log     *slog.Logger
 	m       *metrics.Metrics
+	events  *events.Broker
 	connUrl string
 	db      *sql.DB
 	sq      squirrel.StatementBuilderType
@@ -31,6 +41,7 @@ type Client struct {
 func InitClient(
 	log *slog.Logger,
 	m *metrics.Metrics,
+	broker *events.Broker,
 	user, pass, host, dbName string,
 	disableSSL bool,
 	now func() time.Time,
@@ -67,6 +78,7 @@ func InitClient(
 	return &Client{
 		log:     log,
 		m:       m,
+		events:  broker,
 		connUrl: connUrl,
 		db:      db,
 		sq:      squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
@@ -75,7 +87,8 @@ func InitClient(
 }
 
 // InitTTLCleanup starts a background goroutine that deletes users whose updated_at is older than olderThan,
-// running at the given interval. It stops when the provided context is cancelled.
+// running at the given interval. Boards owned by an account are kept. It stops when the provided context is
+// cancelled.
 func (c *Client) InitTTLCleanup(ctx context.Context, interval, olderThan time.Duration) {
 	go func() {
 		ticker := time.NewTicker(interval)
@@ -88,6 +101,7 @@ func (c *Client) InitTTLCleanup(ctx context.Context, interval, olderThan time.Du
 				sqlStr, args, err := c.sq.
 					Delete("users").
 					Where(squirrel.Lt{"updated_at": cutoff}).
+					Where(squirrel.Eq{"account_id": nil}).
 					ToSql()
 				if err != nil {
 					c.log.Error("TTL cleanup SQL build failed", "error", err)
@@ -107,51 +121,195 @@ func (c *Client) InitTTLCleanup(ctx context.Context, interval, olderThan time.Du
 	}()
 }
 
-// AddToUser adds a ticket to the user's tickets array in the users table.
-func (c *Client) AddToUser(ctx context.Context, id string, ticket models.Ticket) error {
-	// Fetch existing tickets
-	var ticketsJSON []byte
-	sqlStr, args, err := c.sq.
-		Select("tickets").
+// InitAutoArchive starts a background goroutine that archives the done
+// tickets of every board which were not touched for longer than the board's
+// archive_after_days, running at the given interval. It stops when the
+// provided context is cancelled. Boards are not marked as updated, so
+// archiving does not keep them from the TTL cleanup.
+func (c *Client) InitAutoArchive(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := c.autoArchive(ctx)
+				if err != nil {
+					c.log.Error("Auto archive failed", "error", err)
+				} else {
+					c.log.Info("Auto archive ran successfully", "boards", n)
+				}
+			case <-ctx.Done():
+				c.log.Info("Auto archive worker stopped")
+				return
+			}
+		}
+	}()
+}
+
+// staleDoneTicket matches the tickets t which are due to be archived, its
+// placeholder is the current time.
+const staleDoneTicket = `t->>'Status' = 'done'
+	AND t->>'ArchivedAt' IS NULL
+	AND (t->>'LastUpdatedAt')::timestamptz < ?::timestamptz - make_interval(days => archive_after_days)`
+
+func (c *Client) autoArchive(ctx context.Context) (int64, error) {
+	now := c.now()
+	archivedAt := now.Format(time.RFC3339Nano)
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// The boards are locked before their tickets are kept as the old ones,
+	// so a change committed in between cannot go missing from the diff.
+	stale := squirrel.
+		Select("id AS old_id", "tickets AS old_tickets").
 		From("users").
-		Where(squirrel.Eq{"id": id}).
+		Where("archive_after_days > 0").
+		Where(squirrel.Expr(`EXISTS (
+			SELECT 1 FROM jsonb_array_elements(tickets) AS e(t)
+			WHERE `+staleDoneTicket+`
+		)`, now)).
+		Suffix("FOR UPDATE")
+	sqlStr, args, err := c.sq.
+		Update("users").
+		PrefixExpr(squirrel.ConcatExpr("WITH old AS (", stale, ")")).
+		Set("tickets", squirrel.Expr(`(
+			SELECT jsonb_agg(
+				CASE WHEN `+staleDoneTicket+`
+				THEN t || jsonb_build_object('ArchivedAt', ?::text, 'LastUpdatedAt', ?::text)
+				ELSE t END
+				ORDER BY n
+			)
+			FROM jsonb_array_elements(tickets) WITH ORDINALITY AS e(t, n)
+		)`, now, archivedAt, archivedAt)).
+		Set("version", squirrel.Expr("version + 1")).
+		From("old").
+		Where("old.old_id = users.id").
+		Suffix("RETURNING users.id, users.key_prefix, old.old_tickets, users.tickets").
 		ToSql()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	row := c.db.QueryRowContext(ctx, sqlStr, args...)
-	if err := row.Scan(&ticketsJSON); err != nil {
-		return err
+	rows, err := tx.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return 0, err
+	}
+	type archived struct {
+		id, keyPrefix string
+		old, new      []models.Ticket
+	}
+	boards := []archived{}
+	for rows.Next() {
+		var b archived
+		var oldJSON, newJSON []byte
+		if err := rows.Scan(&b.id, &b.keyPrefix, &oldJSON, &newJSON); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if err := json.Unmarshal(oldJSON, &b.old); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if err := json.Unmarshal(newJSON, &b.new); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		boards = append(boards, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
 	}
 
-	var tickets []models.Ticket
-	if err := json.Unmarshal(ticketsJSON, &tickets); err != nil {
-		return err
+	for _, b := range boards {
+		for i := range b.old {
+			b.old[i].Key = models.FormatKey(b.keyPrefix, b.old[i].Number)
+		}
+		if err := c.afterBoardChange(ctx, tx, b.id, b.keyPrefix, b.old, b.new); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
 	}
+	for _, b := range boards {
+		c.publish(b.id)
+	}
+	return int64(len(boards)), nil
+}
 
-	// Append new ticket
-	tickets = append(tickets, ticket)
-	newTicketsJSON, err := json.Marshal(tickets)
+// publish tells everyone watching the board that it changed.
+func (c *Client) publish(boardId string) {
+	if c.events == nil {
+		return
+	}
+	c.events.Publish(events.Event{BoardId: boardId})
+}
+
+// AddToUser adds a ticket to the user's tickets array in the users table.
+// The ticket is given the next sequential number of the board and returned
+// with its key filled in.
+func (c *Client) AddToUser(ctx context.Context, id string, ticket models.Ticket) (models.Ticket, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return models.Ticket{}, err
 	}
-	updateSQL, updateArgs, err := c.sq.
+	defer tx.Rollback()
+
+	board, err := c.lockBoard(ctx, tx, squirrel.Eq{"id": id})
+	if err != nil {
+		return models.Ticket{}, err
+	}
+
+	// Allocate the ticket number
+	sqlStr, args, err := c.sq.
 		Update("users").
-		Set("tickets", newTicketsJSON).
-		Set("updated_at", c.now()).
+		Set("next_key", squirrel.Expr("next_key + 1")).
 		Where(squirrel.Eq{"id": id}).
+		Suffix("RETURNING next_key - 1").
 		ToSql()
 	if err != nil {
-		return err
+		return models.Ticket{}, err
 	}
-	_, err = c.db.ExecContext(ctx, updateSQL, updateArgs...)
-	return err
+	if err := tx.QueryRowContext(ctx, sqlStr, args...).Scan(&ticket.Number); err != nil {
+		return models.Ticket{}, err
+	}
+	ticket.Key = models.FormatKey(board.KeyPrefix, ticket.Number)
+
+	tickets := append(slices.Clone(board.Tickets), ticket)
+	if err := c.writeTickets(ctx, tx, board, tickets); err != nil {
+		return models.Ticket{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Ticket{}, err
+	}
+	c.publish(id)
+	return ticket, nil
 }
 
 // CreateUser creates a new user and returns the user's ID.
 func (c *Client) CreateUser(ctx context.Context) (string, error) {
-	defaultTicketsString, err := json.Marshal(defaultTickets)
+	return c.createBoard(ctx, c.db, nil)
+}
+
+// createBoard creates a board holding the default tickets, owned by the
+// account with accountId unless it is nil, and returns its id.
+func (c *Client) createBoard(ctx context.Context, e squirrel.ExecerContext, accountId any) (string, error) {
+	tickets := make([]models.Ticket, len(defaultTickets))
+	for i, t := range defaultTickets {
+		t.Number = i + 1
+		t.Transitions = []models.Transition{{To: t.Status, At: t.CreatedAt}}
+		tickets[i] = t
+	}
+
+	defaultTicketsString, err := json.Marshal(tickets)
 	if err != nil {
 		return "", err
 	}
@@ -159,61 +317,45 @@ func (c *Client) CreateUser(ctx context.Context) (string, error) {
 	id := uuid.NewString()
 	insertSQL, insertArgs, err := c.sq.
 		Insert("users").
-		Columns("id", "tickets", "updated_at").
-		Values(id, []byte(defaultTicketsString), c.now()).
+		Columns("id", "tickets", "next_key", "updated_at", "account_id").
+		Values(id, []byte(defaultTicketsString), len(tickets)+1, c.now(), accountId).
 		ToSql()
 	if err != nil {
 		return "", err
 	}
-	_, err = c.db.ExecContext(ctx, insertSQL, insertArgs...)
+	_, err = e.ExecContext(ctx, insertSQL, insertArgs...)
 	if err != nil {
 		return "", err
 	}
 	return id, nil
 }
 
-// DeleteTodoByUserAndTodoId removes a specific ticket from a user's tickets array.
-func (c *Client) DeleteTodoByUserAndTodoId(ctx context.Context, userId string, todoId string) error {
+// DeleteTodoByUserAndTodoId removes a specific ticket from a user's tickets array
+// and returns the removed ticket.
+func (c *Client) DeleteTodoByUserAndTodoId(ctx context.Context, userId string, todoId string) (models.Ticket, error) {
 	// Fetch tickets
-	var ticketsJSON []byte
-	sqlStr, args, err := c.sq.
-		Select("tickets").
-		From("users").
-		Where(squirrel.Eq{"id": userId}).
-		ToSql()
+	board, err := c.GetBoard(ctx, userId)
 	if err != nil {
-		return err
-	}
-	row := c.db.QueryRowContext(ctx, sqlStr, args...)
-	if err := row.Scan(&ticketsJSON); err != nil {
-		return err
-	}
-	var tickets []models.Ticket
-	if err := json.Unmarshal(ticketsJSON, &tickets); err != nil {
-		return err
+		return models.Ticket{}, err
 	}
 	// Remove ticket by todoId
-	newTickets := make([]models.Ticket, 0, len(tickets))
-	for _, t := range tickets {
+	var deleted *models.Ticket
+	newTickets := make([]models.Ticket, 0, len(board.Tickets))
+	for _, t := range board.Tickets {
 		if t.Id != todoId {
 			newTickets = append(newTickets, t)
+			continue
 		}
+		deleted = &t
 	}
-	newTicketsJSON, err := json.Marshal(newTickets)
-	if err != nil {
-		return err
+	if deleted == nil {
+		return models.Ticket{}, ErrTicketNotFound
 	}
-	updateSQL, updateArgs, err := c.sq.
-		Update("users").
-		Set("tickets", newTicketsJSON).
-		Set("updated_at", c.now()).
-		Where(squirrel.Eq{"id": userId}).
-		ToSql()
-	if err != nil {
-		return err
+	board.Tickets = newTickets
+	if err := c.UpdateBoard(ctx, board); err != nil {
+		return models.Ticket{}, err
 	}
-	_, err = c.db.ExecContext(ctx, updateSQL, updateArgs...)
-	return err
+	return *deleted, nil
 }
 
 // DeleteUserByID deletes a user by ID.
@@ -229,49 +371,150 @@ func (c *Client) DeleteUserByID(ctx context.Context, id string) error {
 	return err
 }
 
-// GetAllByUser returns all tickets for a user.
-func (c *Client) GetAllByUser(ctx context.Context, id string) ([]models.Ticket, error) {
+// GetBoard returns the user's board with the keys of all tickets filled in.
+func (c *Client) GetBoard(ctx context.Context, id string) (models.Board, error) {
 	sqlStr, args, err := c.sq.
-		Select("tickets").
+		Select("tickets", "key_prefix", "version", "archive_after_days").
 		From("users").
 		Where(squirrel.Eq{"id": id}).
 		ToSql()
 	if err != nil {
-		return nil, err
+		return models.Board{}, err
 	}
 	row := c.db.QueryRowContext(ctx, sqlStr, args...)
+	board := models.Board{Id: id}
 	var ticketsJSON []byte
-	if err := row.Scan(&ticketsJSON); err != nil {
-		return nil, err
+	if err := row.Scan(&ticketsJSON, &board.KeyPrefix, &board.Version, &board.ArchiveAfterDays); err != nil {
+		return models.Board{}, err
 	}
-	var tickets []models.Ticket
-	if err := json.Unmarshal(ticketsJSON, &tickets); err != nil {
-		return nil, err
+	if err := json.Unmarshal(ticketsJSON, &board.Tickets); err != nil {
+		return models.Board{}, err
+	}
+	for i := range board.Tickets {
+		board.Tickets[i].Key = models.FormatKey(board.KeyPrefix, board.Tickets[i].Number)
 	}
-	return tickets, nil
+	return board, nil
 }
 
-// GetAllByUserSplitByStatus returns tickets split by status.
-func (c *Client) GetAllByUserSplitByStatus(ctx context.Context, id string) (todo, inProgress, done []models.Ticket, err error) {
-	tickets, err := c.GetAllByUser(ctx, id)
+// GetTicketByUser returns a single ticket of the user, looked up either by its
+// id or by its human readable key.
+func (c *Client) GetTicketByUser(ctx context.Context, userId string, ref string) (models.Ticket, error) {
+	board, err := c.GetBoard(ctx, userId)
 	if err != nil {
-		return nil, nil, nil, err
-	}
-	for _, t := range tickets {
-		switch t.Status {
-		case models.StatusTodo:
-			todo = append(todo, t)
-		case models.StatusInProgress:
-			inProgress = append(inProgress, t)
-		case models.StatusDone:
-			done = append(done, t)
+		return models.Ticket{}, err
+	}
+
+	prefix, number, isKey := models.ParseKey(ref)
+	for _, t := range board.Tickets {
+		if t.Id == ref || (isKey && prefix == board.KeyPrefix && t.Number == number) {
+			return t, nil
 		}
 	}
-	return todo, inProgress, done, nil
+	return models.Ticket{}, ErrTicketNotFound
+}
+
+// UpdateBoardSettings changes the settings of the user's board.
+func (c *Client) UpdateBoardSettings(ctx context.Context, userId string, settings models.BoardSettings) error {
+	updateSQL, updateArgs, err := c.sq.
+		Update("users").
+		Set("key_prefix", settings.KeyPrefix).
+		Set("archive_after_days", settings.ArchiveAfterDays).
+		Set("updated_at", c.now()).
+		Where(squirrel.Eq{"id": userId}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, updateSQL, updateArgs...); err != nil {
+		return err
+	}
+	c.publish(userId)
+	return nil
+}
+
+// UpdateTicket replaces a single ticket in the user's tickets array, matched
+// by its id.
+func (c *Client) UpdateTicket(ctx context.Context, userId string, ticket models.Ticket) error {
+	board, err := c.GetBoard(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i, t := range board.Tickets {
+		if t.Id == ticket.Id {
+			board.Tickets[i] = ticket
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ErrTicketNotFound
+	}
+
+	return c.UpdateBoard(ctx, board)
+}
+
+// UpdateBoard replaces the tickets of the board, as long as nothing else
+// changed it since it was read. It returns ErrConflict otherwise.
+func (c *Client) UpdateBoard(ctx context.Context, board models.Board) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := c.lockBoard(ctx, tx, squirrel.Eq{"id": board.Id, "version": board.Version})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := c.writeTickets(ctx, tx, old, board.Tickets); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	c.publish(board.Id)
+	return nil
+}
+
+// lockBoard reads the board matching where within tx, holding a lock on it so
+// nothing else can change it until tx ends. It returns sql.ErrNoRows when no
+// board matches.
+func (c *Client) lockBoard(ctx context.Context, tx *sql.Tx, where squirrel.Eq) (models.Board, error) {
+	sqlStr, args, err := c.sq.
+		Select("id", "tickets", "key_prefix").
+		From("users").
+		Where(where).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		return models.Board{}, err
+	}
+
+	board := models.Board{}
+	var ticketsJSON []byte
+	row := tx.QueryRowContext(ctx, sqlStr, args...)
+	if err := row.Scan(&board.Id, &ticketsJSON, &board.KeyPrefix); err != nil {
+		return models.Board{}, err
+	}
+	if err := json.Unmarshal(ticketsJSON, &board.Tickets); err != nil {
+		return models.Board{}, err
+	}
+	for i := range board.Tickets {
+		board.Tickets[i].Key = models.FormatKey(board.KeyPrefix, board.Tickets[i].Number)
+	}
+	return board, nil
 }
 
-// UpdateUser replaces the user's tickets array.
-func (c *Client) UpdateUser(ctx context.Context, userId string, tickets []models.Ticket) error {
+// writeTickets replaces the tickets of the board locked by lockBoard, along
+// with everything that follows from the change.
+func (c *Client) writeTickets(ctx context.Context, tx *sql.Tx, board models.Board, tickets []models.Ticket) error {
 	ticketsJSON, err := json.Marshal(tickets)
 	if err != nil {
 		return err
@@ -279,12 +522,76 @@ func (c *Client) UpdateUser(ctx context.Context, userId string, tickets []models
 	updateSQL, updateArgs, err := c.sq.
 		Update("users").
 		Set("tickets", ticketsJSON).
+		Set("version", squirrel.Expr("version + 1")).
 		Set("updated_at", c.now()).
-		Where(squirrel.Eq{"id": userId}).
+		Where(squirrel.Eq{"id": board.Id}).
 		ToSql()
 	if err != nil {
 		return err
 	}
-	_, err = c.db.ExecContext(ctx, updateSQL, updateArgs...)
-	return err
+	if _, err := tx.ExecContext(ctx, updateSQL, updateArgs...); err != nil {
+		return err
+	}
+	if err := c.rememberDeletedExternalRefs(ctx, tx, board.Id, board.Tickets, tickets); err != nil {
+		return err
+	}
+	return c.afterBoardChange(ctx, tx, board.Id, board.KeyPrefix, board.Tickets, tickets)
+}
+
+// afterBoardChange records the changes made to the tickets of the board in
+// its activity and queues them for its webhooks, within the transaction of
+// the change.
+func (c *Client) afterBoardChange(ctx context.Context, tx *sql.Tx, boardId, keyPrefix string, old, new []models.Ticket) error {
+	keyed := make([]models.Ticket, len(new))
+	for i, t := range new {
+		t.Key = models.FormatKey(keyPrefix, t.Number)
+		keyed[i] = t
+	}
+	changes := models.DiffTickets(old, keyed)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	now := c.now()
+	if err := c.recordActivity(ctx, tx, boardId, changes, now); err != nil {
+		return err
+	}
+	return c.queueDeliveries(ctx, tx, boardId, changes, now)
+}
+
+// BulkUpdate applies the operation to the tickets with the given ids in a
+// single transaction, holding a lock on the board so nothing else can change
+// it in the meantime. It returns the affected tickets.
+func (c *Client) BulkUpdate(ctx context.Context, userId string, ids []string, op models.BulkOperation) ([]models.Ticket, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	board, err := c.lockBoard(ctx, tx, squirrel.Eq{"id": userId})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if !slices.ContainsFunc(board.Tickets, func(t models.Ticket) bool { return t.Id == id }) {
+			return nil, ErrTicketNotFound
+		}
+	}
+
+	tickets, affected, err := models.ApplyBulk(board.Tickets, ids, op, c.now())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.writeTickets(ctx, tx, board, tickets); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	c.publish(userId)
+	return affected, nil
 }
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+)
+
+var ErrFeedNotFound = errors.New("feed not found")
+
+// GetFeedToken returns the token of the activity feed of the board, or an
+// empty string when it has none.
+func (c *Client) GetFeedToken(ctx context.Context, boardId string) (string, error) {
+	return c.getBoardToken(ctx, "feed_token", boardId)
+}
+
+// ResetFeedToken gives the board a new activity feed token, the feed is no
+// longer served under the old one.
+func (c *Client) ResetFeedToken(ctx context.Context, boardId string) (string, error) {
+	return c.resetBoardToken(ctx, "feed_token", boardId)
+}
+
+// DisableFeedToken stops serving the activity feed of the board.
+func (c *Client) DisableFeedToken(ctx context.Context, boardId string) error {
+	return c.setBoardToken(ctx, "feed_token", boardId, nil)
+}
+
+// GetFeedBoardId returns the id of the board the activity feed token belongs
+// to. It returns ErrFeedNotFound when no board has it.
+func (c *Client) GetFeedBoardId(ctx context.Context, token string) (string, error) {
+	return c.boardIdByToken(ctx, "feed_token", token, ErrFeedNotFound)
+}
+
+// GetActivity returns the latest activity on the board, newest first.
+func (c *Client) GetActivity(ctx context.Context, boardId string, limit int) ([]models.Activity, error) {
+	sqlStr, args, err := c.sq.
+		Select("id", "board_id", "ticket_id", "ticket_key", "title", "kind", "from_status", "to_status", "created_at").
+		From("activity").
+		Where(squirrel.Eq{"board_id": boardId}).
+		OrderBy("created_at DESC", "id").
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activity := []models.Activity{}
+	for rows.Next() {
+		var a models.Activity
+		if err := rows.Scan(&a.Id, &a.BoardId, &a.TicketId, &a.TicketKey, &a.Title, &a.Kind, &a.From, &a.To, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		activity = append(activity, a)
+	}
+	return activity, rows.Err()
+}
+
+// recordActivity adds the changes made to the tickets of the board to its
+// activity, leaving out those which are not followed.
+func (c *Client) recordActivity(ctx context.Context, tx *sql.Tx, boardId string, changes []models.TicketChange, now time.Time) error {
+	insert := c.sq.
+		Insert("activity").
+		Columns("id", "board_id", "ticket_id", "ticket_key", "title", "kind", "from_status", "to_status", "created_at")
+	recorded := 0
+	for _, change := range changes {
+		a, ok := models.NewActivity(boardId, change, now)
+		if !ok {
+			continue
+		}
+		insert = insert.Values(uuid.NewString(), a.BoardId, a.TicketId, a.TicketKey, a.Title, a.Kind, a.From, a.To, a.CreatedAt)
+		recorded++
+	}
+	if recorded == 0 {
+		return nil
+	}
+
+	sqlStr, args, err := insert.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// InitActivityCleanup starts a background goroutine that deletes activity
+// older than olderThan, running at the given interval. It stops when the
+// provided context is cancelled.
+func (c *Client) InitActivityCleanup(ctx context.Context, interval, olderThan time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sqlStr, args, err := c.sq.
+					Delete("activity").
+					Where(squirrel.Lt{"created_at": c.now().Add(-olderThan)}).
+					ToSql()
+				if err != nil {
+					c.log.Error("Activity cleanup SQL build failed", "error", err)
+					continue
+				}
+				res, err := c.db.ExecContext(ctx, sqlStr, args...)
+				if err != nil {
+					c.log.Error("Activity cleanup failed", "error", err)
+					continue
+				}
+				n, _ := res.RowsAffected()
+				c.log.Info("Activity cleanup ran successfully", "deleted", n)
+			case <-ctx.Done():
+				c.log.Info("Activity cleanup worker stopped")
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,219 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+)
+
+var ErrGitHubHookNotFound = errors.New("github hook not found")
+
+// CreateGitHubHook creates a new endpoint for GitHub to post the issue events
+// of a repository to, mirroring them on the board.
+func (c *Client) CreateGitHubHook(ctx context.Context, boardId string) (models.GitHubHook, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return models.GitHubHook{}, err
+	}
+	hook := models.GitHubHook{
+		Id:        uuid.NewString(),
+		BoardId:   boardId,
+		Secret:    secret,
+		CreatedAt: c.now(),
+	}
+	sqlStr, args, err := c.sq.
+		Insert("github_hooks").
+		Columns("id", "board_id", "secret", "created_at").
+		Values(hook.Id, hook.BoardId, hook.Secret, hook.CreatedAt).
+		ToSql()
+	if err != nil {
+		return models.GitHubHook{}, err
+	}
+	if _, err := c.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return models.GitHubHook{}, err
+	}
+	return hook, nil
+}
+
+// GetGitHubHooks returns the GitHub endpoints of the board, newest first.
+func (c *Client) GetGitHubHooks(ctx context.Context, boardId string) ([]models.GitHubHook, error) {
+	sqlStr, args, err := c.sq.
+		Select("id", "board_id", "secret", "created_at").
+		From("github_hooks").
+		Where(squirrel.Eq{"board_id": boardId}).
+		OrderBy("created_at DESC").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []models.GitHubHook{}
+	for rows.Next() {
+		var h models.GitHubHook
+		if err := rows.Scan(&h.Id, &h.BoardId, &h.Secret, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+// GetGitHubHook returns the GitHub endpoint with the given id, whichever board
+// it belongs to. It returns ErrGitHubHookNotFound when there is none.
+func (c *Client) GetGitHubHook(ctx context.Context, id string) (models.GitHubHook, error) {
+	if uuid.Validate(id) != nil {
+		return models.GitHubHook{}, ErrGitHubHookNotFound
+	}
+	sqlStr, args, err := c.sq.
+		Select("id", "board_id", "secret", "created_at").
+		From("github_hooks").
+		Where(squirrel.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return models.GitHubHook{}, err
+	}
+	var h models.GitHubHook
+	err = c.db.QueryRowContext(ctx, sqlStr, args...).Scan(&h.Id, &h.BoardId, &h.Secret, &h.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.GitHubHook{}, ErrGitHubHookNotFound
+	}
+	return h, err
+}
+
+// DeleteGitHubHook removes a GitHub endpoint of the board, anything posted to
+// it afterwards is turned away.
+func (c *Client) DeleteGitHubHook(ctx context.Context, boardId, id string) error {
+	if uuid.Validate(id) != nil {
+		return ErrGitHubHookNotFound
+	}
+	sqlStr, args, err := c.sq.
+		Delete("github_hooks").
+		Where(squirrel.Eq{"id": id, "board_id": boardId}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	res, err := c.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrGitHubHookNotFound
+	}
+	return nil
+}
+
+// SyncExternalTicket applies models.SyncExternalTicket to the board in a
+// single transaction, so the same external ticket arriving twice at once is
+// only added once. New tickets are given the next number of the board, unless
+// a ticket mirroring the same thing was deleted from the board before, which
+// stays deleted. It returns the ticket and what was done to it, which is
+// empty when nothing was.
+func (c *Client) SyncExternalTicket(ctx context.Context, boardId string, incoming models.Ticket, move bool) (models.Ticket, models.TicketEvent, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Ticket{}, "", err
+	}
+	defer tx.Rollback()
+
+	board, err := c.lockBoard(ctx, tx, squirrel.Eq{"id": boardId})
+	if err != nil {
+		return models.Ticket{}, "", err
+	}
+
+	tickets, ticket, event := models.SyncExternalTicket(board.Tickets, incoming, move, c.now())
+	if event == "" {
+		return ticket, "", nil
+	}
+
+	if event == models.TicketCreated {
+		deleted, err := c.externalRefDeleted(ctx, tx, boardId, ticket.ExternalRef)
+		if err != nil {
+			return models.Ticket{}, "", err
+		}
+		if deleted {
+			return models.Ticket{}, "", nil
+		}
+
+		sqlStr, args, err := c.sq.
+			Update("users").
+			Set("next_key", squirrel.Expr("next_key + 1")).
+			Where(squirrel.Eq{"id": boardId}).
+			Suffix("RETURNING next_key - 1").
+			ToSql()
+		if err != nil {
+			return models.Ticket{}, "", err
+		}
+		if err := tx.QueryRowContext(ctx, sqlStr, args...).Scan(&ticket.Number); err != nil {
+			return models.Ticket{}, "", err
+		}
+		ticket.Key = models.FormatKey(board.KeyPrefix, ticket.Number)
+		tickets[len(tickets)-1] = ticket
+	}
+
+	if err := c.writeTickets(ctx, tx, board, tickets); err != nil {
+		return models.Ticket{}, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Ticket{}, "", err
+	}
+	c.publish(boardId)
+	return ticket, event, nil
+}
+
+// externalRefDeleted reports whether a ticket mirroring ref was deleted from
+// the board.
+func (c *Client) externalRefDeleted(ctx context.Context, tx *sql.Tx, boardId, ref string) (bool, error) {
+	sqlStr, args, err := c.sq.
+		Select("1").
+		From("deleted_external_refs").
+		Where(squirrel.Eq{"board_id": boardId, "ref": ref}).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+	var one int
+	err = tx.QueryRowContext(ctx, sqlStr, args...).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// rememberDeletedExternalRefs keeps the refs of the mirrored tickets deleted
+// by a change of the board, so they are not mirrored onto it again.
+func (c *Client) rememberDeletedExternalRefs(ctx context.Context, e squirrel.ExecerContext, boardId string, old, new []models.Ticket) error {
+	refs := models.DeletedExternalRefs(old, new)
+	if len(refs) == 0 {
+		return nil
+	}
+
+	now := c.now()
+	insert := c.sq.
+		Insert("deleted_external_refs").
+		Columns("board_id", "ref", "deleted_at")
+	for _, ref := range refs {
+		insert = insert.Values(boardId, ref, now)
+	}
+	sqlStr, args, err := insert.Suffix("ON CONFLICT DO NOTHING").ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = e.ExecContext(ctx, sqlStr, args...)
+	return err
+}
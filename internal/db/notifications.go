@@ -0,0 +1,230 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/util"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// AddNotifications keeps the notifications in the inboxes of their users, in
+// a single insert. Ids are given to them on the way.
+func (c *Client) AddNotifications(ctx context.Context, notifications []models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	insert := c.sq.
+		Insert("notifications").
+		Columns("id", "user_id", "type", "title", "content", "actions", "created_at")
+	for i, n := range notifications {
+		actions := n.Message.Actions
+		if actions == nil {
+			actions = []util.MessageAction{}
+		}
+		actionsJSON, err := json.Marshal(actions)
+		if err != nil {
+			return err
+		}
+		notifications[i].Id = uuid.NewString()
+		insert = insert.Values(notifications[i].Id, n.UserId, n.Message.Type, n.Message.Title, n.Message.Content, actionsJSON, n.CreatedAt)
+	}
+
+	sqlStr, args, err := insert.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = c.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// GetNotifications returns the latest notifications in the inbox of the user,
+// newest first.
+func (c *Client) GetNotifications(ctx context.Context, userId string, limit int) ([]models.Notification, error) {
+	sqlStr, args, err := c.sq.
+		Select("id", "user_id", "type", "title", "content", "actions", "created_at", "read_at").
+		From("notifications").
+		Where(squirrel.Eq{"user_id": userId}).
+		OrderBy("created_at DESC").
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		var actionsJSON []byte
+		err := rows.Scan(&n.Id, &n.UserId, &n.Message.Type, &n.Message.Title, &n.Message.Content,
+			&actionsJSON, &n.CreatedAt, &n.ReadAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(actionsJSON, &n.Message.Actions); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// CountUnreadNotifications returns how many notifications in the inbox of the
+// user are not read yet.
+func (c *Client) CountUnreadNotifications(ctx context.Context, userId string) (int, error) {
+	sqlStr, args, err := c.sq.
+		Select("COUNT(*)").
+		From("notifications").
+		Where(squirrel.Eq{"user_id": userId, "read_at": nil}).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = c.db.QueryRowContext(ctx, sqlStr, args...).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationRead marks a single notification of the user as read.
+func (c *Client) MarkNotificationRead(ctx context.Context, userId, id string) error {
+	if uuid.Validate(id) != nil {
+		return ErrNotificationNotFound
+	}
+	sqlStr, args, err := c.sq.
+		Update("notifications").
+		Set("read_at", squirrel.Expr("COALESCE(read_at, ?)", c.now())).
+		Where(squirrel.Eq{"id": id, "user_id": userId}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	res, err := c.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks every notification of the user as read.
+func (c *Client) MarkAllNotificationsRead(ctx context.Context, userId string) error {
+	sqlStr, args, err := c.sq.
+		Update("notifications").
+		Set("read_at", c.now()).
+		Where(squirrel.Eq{"user_id": userId, "read_at": nil}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = c.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// ClearNotifications empties the inbox of the user.
+func (c *Client) ClearNotifications(ctx context.Context, userId string) error {
+	sqlStr, args, err := c.sq.
+		Delete("notifications").
+		Where(squirrel.Eq{"user_id": userId}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = c.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// GetMutedMessageTypes returns the message types the user does not want to
+// see toasts of.
+func (c *Client) GetMutedMessageTypes(ctx context.Context, userId string) ([]util.MessageType, error) {
+	sqlStr, args, err := c.sq.
+		Select("muted_message_types").
+		From("users").
+		Where(squirrel.Eq{"id": userId}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	var mutedJSON []byte
+	if err := c.db.QueryRowContext(ctx, sqlStr, args...).Scan(&mutedJSON); err != nil {
+		return nil, err
+	}
+	muted := []util.MessageType{}
+	if err := json.Unmarshal(mutedJSON, &muted); err != nil {
+		return nil, err
+	}
+	return muted, nil
+}
+
+// SetMutedMessageTypes changes the message types the user does not want to
+// see toasts of.
+func (c *Client) SetMutedMessageTypes(ctx context.Context, userId string, muted []util.MessageType) error {
+	if muted == nil {
+		muted = []util.MessageType{}
+	}
+	mutedJSON, err := json.Marshal(muted)
+	if err != nil {
+		return err
+	}
+	sqlStr, args, err := c.sq.
+		Update("users").
+		Set("muted_message_types", mutedJSON).
+		Where(squirrel.Eq{"id": userId}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = c.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// InitNotificationCleanup starts a background goroutine that deletes
+// notifications older than olderThan, running at the given interval. It stops
+// when the provided context is cancelled.
+func (c *Client) InitNotificationCleanup(ctx context.Context, interval, olderThan time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sqlStr, args, err := c.sq.
+					Delete("notifications").
+					Where(squirrel.Lt{"created_at": c.now().Add(-olderThan)}).
+					ToSql()
+				if err != nil {
+					c.log.Error("Notification cleanup SQL build failed", "error", err)
+					continue
+				}
+				res, err := c.db.ExecContext(ctx, sqlStr, args...)
+				if err != nil {
+					c.log.Error("Notification cleanup failed", "error", err)
+					continue
+				}
+				n, _ := res.RowsAffected()
+				c.log.Info("Notification cleanup ran successfully", "deleted", n)
+			case <-ctx.Done():
+				c.log.Info("Notification cleanup worker stopped")
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+)
+
+var ErrShareLinkNotFound = errors.New("share link not found")
+
+// CreateShareLink creates a new share link for the board.
+func (c *Client) CreateShareLink(ctx context.Context, boardId string) (models.ShareLink, error) {
+	link := models.ShareLink{
+		Id:        uuid.NewString(),
+		BoardId:   boardId,
+		CreatedAt: c.now(),
+	}
+	sqlStr, args, err := c.sq.
+		Insert("share_links").
+		Columns("id", "board_id", "created_at").
+		Values(link.Id, link.BoardId, link.CreatedAt).
+		ToSql()
+	if err != nil {
+		return models.ShareLink{}, err
+	}
+	if _, err := c.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return models.ShareLink{}, err
+	}
+	return link, nil
+}
+
+// GetShareLinks returns the share links of the board which were not revoked,
+// newest first.
+func (c *Client) GetShareLinks(ctx context.Context, boardId string) ([]models.ShareLink, error) {
+	sqlStr, args, err := c.sq.
+		Select("id", "board_id", "created_at", "revoked_at").
+		From("share_links").
+		Where(squirrel.Eq{"board_id": boardId, "revoked_at": nil}).
+		OrderBy("created_at DESC").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []models.ShareLink{}
+	for rows.Next() {
+		var l models.ShareLink
+		if err := rows.Scan(&l.Id, &l.BoardId, &l.CreatedAt, &l.RevokedAt); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// GetSharedBoardId returns the id of the board the share link gives access
+// to. It returns ErrShareLinkNotFound when the link does not exist or was
+// revoked.
+func (c *Client) GetSharedBoardId(ctx context.Context, linkId string) (string, error) {
+	if uuid.Validate(linkId) != nil {
+		return "", ErrShareLinkNotFound
+	}
+	sqlStr, args, err := c.sq.
+		Select("board_id").
+		From("share_links").
+		Where(squirrel.Eq{"id": linkId, "revoked_at": nil}).
+		ToSql()
+	if err != nil {
+		return "", err
+	}
+	var boardId string
+	err = c.db.QueryRowContext(ctx, sqlStr, args...).Scan(&boardId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrShareLinkNotFound
+	}
+	return boardId, err
+}
+
+// RevokeShareLink revokes a share link of the board. Anyone watching the
+// board through it is told, so they stop getting updates.
+func (c *Client) RevokeShareLink(ctx context.Context, boardId, linkId string) error {
+	if uuid.Validate(linkId) != nil {
+		return ErrShareLinkNotFound
+	}
+	sqlStr, args, err := c.sq.
+		Update("share_links").
+		Set("revoked_at", c.now()).
+		Where(squirrel.Eq{"id": linkId, "board_id": boardId, "revoked_at": nil}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	res, err := c.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrShareLinkNotFound
+	}
+	c.publish(boardId)
+	return nil
+}
@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS key_prefix TEXT NOT NULL DEFAULT 'LB';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS next_key INTEGER NOT NULL DEFAULT 1;
+
+-- Number existing tickets in the order they are stored on the board.
+UPDATE users u
+SET tickets = numbered.tickets,
+    next_key = numbered.count + 1
+FROM (
+    SELECT users.id,
+           jsonb_agg(t.ticket || jsonb_build_object('Number', t.n) ORDER BY t.n) AS tickets,
+           COUNT(*) AS count
+    FROM users, jsonb_array_elements(users.tickets) WITH ORDINALITY AS t(ticket, n)
+    GROUP BY users.id
+) AS numbered
+WHERE u.id = numbered.id;
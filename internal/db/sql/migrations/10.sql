@@ -0,0 +1,17 @@
+-- Secret token of the activity feed of the board, NULL while it has none.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS feed_token TEXT UNIQUE;
+
+-- What happened to the tickets of each board, for its activity feed.
+CREATE TABLE IF NOT EXISTS activity (
+    id          UUID PRIMARY KEY,
+    board_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ticket_id   TEXT NOT NULL,
+    ticket_key  TEXT NOT NULL,
+    title       TEXT NOT NULL,
+    kind        TEXT NOT NULL,
+    from_status TEXT NOT NULL DEFAULT '',
+    to_status   TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_activity_board_id_created_at ON activity (board_id, created_at);
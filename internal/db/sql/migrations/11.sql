@@ -0,0 +1,21 @@
+-- Registered accounts, usernames and emails are kept in lower case.
+CREATE TABLE IF NOT EXISTS accounts (
+    id            UUID PRIMARY KEY,
+    username      TEXT NOT NULL UNIQUE,
+    email         TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The account owning the board, owned boards are kept by the TTL cleanup.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS account_id UUID UNIQUE REFERENCES accounts(id) ON DELETE SET NULL;
+
+-- Pending password resets, only the SHA-256 hash of the emailed token is kept.
+CREATE TABLE IF NOT EXISTS password_resets (
+    token_hash TEXT PRIMARY KEY,
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_account_id ON password_resets (account_id);
@@ -0,0 +1,3 @@
+-- Bumped whenever the sessions of the account have to be revoked, sessions
+-- logged in under an older generation are logged out.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS session_generation INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Bumped on every write so concurrent changes to a board can be detected.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 0;
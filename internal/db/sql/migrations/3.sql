@@ -0,0 +1,2 @@
+-- Done tickets untouched for this many days are archived, 0 turns it off.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS archive_after_days INTEGER NOT NULL DEFAULT 14;
@@ -0,0 +1,9 @@
+-- Read-only share links of boards, kept after revoking so the owner can see them.
+CREATE TABLE IF NOT EXISTS share_links (
+    id         UUID PRIMARY KEY,
+    board_id   UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_share_links_board_id ON share_links (board_id);
@@ -0,0 +1,7 @@
+-- Notifications are util.UiMessage now, with lower case types.
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT '';
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS actions JSONB NOT NULL DEFAULT '[]';
+UPDATE notifications SET type = lower(type);
+
+-- Message types the user does not want to see toasts of.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS muted_message_types JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,17 @@
+-- Endpoints GitHub posts issue events to, each with the secret it signs them with.
+CREATE TABLE IF NOT EXISTS github_hooks (
+    id         UUID PRIMARY KEY,
+    board_id   UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    secret     TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_github_hooks_board_id ON github_hooks (board_id);
+
+-- Mirrored tickets deleted from a board, so they are not mirrored onto it again.
+CREATE TABLE IF NOT EXISTS deleted_external_refs (
+    board_id   UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ref        TEXT NOT NULL,
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (board_id, ref)
+);
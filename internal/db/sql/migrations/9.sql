@@ -0,0 +1,2 @@
+-- Secret token of the calendar feed of the board, NULL while it has none.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS calendar_token TEXT UNIQUE;
@@ -1,12 +1,135 @@
 -- name: schema_up
+CREATE TABLE IF NOT EXISTS accounts (
+    id            UUID PRIMARY KEY,
+    username      TEXT NOT NULL UNIQUE,
+    email         TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    session_generation INTEGER NOT NULL DEFAULT 0,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE TABLE IF NOT EXISTS users (
     id         UUID PRIMARY KEY,
     tickets    JSONB NOT NULL DEFAULT '[]',
+    key_prefix TEXT NOT NULL DEFAULT 'LB',
+    next_key   INTEGER NOT NULL DEFAULT 1,
+    version    INTEGER NOT NULL DEFAULT 0,
+    archive_after_days INTEGER NOT NULL DEFAULT 14,
+    muted_message_types JSONB NOT NULL DEFAULT '[]',
+    calendar_token TEXT UNIQUE,
+    feed_token TEXT UNIQUE,
+    account_id UUID UNIQUE REFERENCES accounts(id) ON DELETE SET NULL,
     updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
 CREATE INDEX IF NOT EXISTS idx_users_updated_at ON users (updated_at);
 
+CREATE TABLE IF NOT EXISTS share_links (
+    id         UUID PRIMARY KEY,
+    board_id   UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_share_links_board_id ON share_links (board_id);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type       TEXT NOT NULL,
+    title      TEXT NOT NULL DEFAULT '',
+    content    TEXT NOT NULL,
+    actions    JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    read_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id_created_at ON notifications (user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id         UUID PRIMARY KEY,
+    board_id   UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url        TEXT NOT NULL,
+    secret     TEXT NOT NULL,
+    events     JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_board_id ON webhooks (board_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              UUID PRIMARY KEY,
+    webhook_id      UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event           TEXT NOT NULL,
+    payload         JSONB NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    status_code     INTEGER NOT NULL DEFAULT 0,
+    error           TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ,
+    last_attempt_at TIMESTAMPTZ,
+    delivered_at    TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id_created_at ON webhook_deliveries (webhook_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_next_attempt_at ON webhook_deliveries (next_attempt_at) WHERE next_attempt_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS github_hooks (
+    id         UUID PRIMARY KEY,
+    board_id   UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    secret     TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_github_hooks_board_id ON github_hooks (board_id);
+
+CREATE TABLE IF NOT EXISTS deleted_external_refs (
+    board_id   UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ref        TEXT NOT NULL,
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (board_id, ref)
+);
+
+CREATE TABLE IF NOT EXISTS activity (
+    id          UUID PRIMARY KEY,
+    board_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ticket_id   TEXT NOT NULL,
+    ticket_key  TEXT NOT NULL,
+    title       TEXT NOT NULL,
+    kind        TEXT NOT NULL,
+    from_status TEXT NOT NULL DEFAULT '',
+    to_status   TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_activity_board_id_created_at ON activity (board_id, created_at);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+    token_hash TEXT PRIMARY KEY,
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_account_id ON password_resets (account_id);
+
 -- name: schema_down
+DROP INDEX IF EXISTS idx_password_resets_account_id;
+DROP TABLE IF EXISTS password_resets;
+DROP INDEX IF EXISTS idx_activity_board_id_created_at;
+DROP TABLE IF EXISTS activity;
+DROP TABLE IF EXISTS deleted_external_refs;
+DROP INDEX IF EXISTS idx_github_hooks_board_id;
+DROP TABLE IF EXISTS github_hooks;
+DROP INDEX IF EXISTS idx_webhook_deliveries_next_attempt_at;
+DROP INDEX IF EXISTS idx_webhook_deliveries_webhook_id_created_at;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP INDEX IF EXISTS idx_webhooks_board_id;
+DROP TABLE IF EXISTS webhooks;
+DROP INDEX IF EXISTS idx_notifications_user_id_created_at;
+DROP TABLE IF EXISTS notifications;
+DROP INDEX IF EXISTS idx_share_links_board_id;
+DROP TABLE IF EXISTS share_links;
 DROP INDEX IF EXISTS idx_users_updated_at;
 DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS accounts;
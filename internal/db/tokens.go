@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// getBoardToken returns the secret token the board keeps in column, or an
+// empty string when it has none.
+func (c *Client) getBoardToken(ctx context.Context, column, boardId string) (string, error) {
+	sqlStr, args, err := c.sq.
+		Select(column).
+		From("users").
+		Where(squirrel.Eq{"id": boardId}).
+		ToSql()
+	if err != nil {
+		return "", err
+	}
+	var token sql.NullString
+	if err := c.db.QueryRowContext(ctx, sqlStr, args...).Scan(&token); err != nil {
+		return "", err
+	}
+	return token.String, nil
+}
+
+// resetBoardToken keeps a new random token in column of the board and
+// returns it.
+func (c *Client) resetBoardToken(ctx context.Context, column, boardId string) (string, error) {
+	token := rand.Text()
+	if err := c.setBoardToken(ctx, column, boardId, token); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// setBoardToken keeps token in column of the board, nil clears it.
+func (c *Client) setBoardToken(ctx context.Context, column, boardId string, token any) error {
+	sqlStr, args, err := c.sq.
+		Update("users").
+		Set(column, token).
+		Where(squirrel.Eq{"id": boardId}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = c.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// boardIdByToken returns the id of the board keeping token in column. It
+// returns notFound when no board does.
+func (c *Client) boardIdByToken(ctx context.Context, column, token string, notFound error) (string, error) {
+	if token == "" {
+		return "", notFound
+	}
+	sqlStr, args, err := c.sq.
+		Select("id").
+		From("users").
+		Where(squirrel.Eq{column: token}).
+		ToSql()
+	if err != nil {
+		return "", err
+	}
+	var boardId string
+	err = c.db.QueryRowContext(ctx, sqlStr, args...).Scan(&boardId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", notFound
+	}
+	return boardId, err
+}
@@ -0,0 +1,345 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+)
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// webhookSecretBytes is the size of the random secret deliveries are signed
+// with.
+const webhookSecretBytes = 32
+
+// newWebhookSecret returns a new random secret for signing webhook
+// deliveries.
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// CreateWebhook adds a webhook to the board, giving it an id and a new random
+// secret.
+func (c *Client) CreateWebhook(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	webhook.Id = uuid.NewString()
+	webhook.Secret = secret
+	webhook.CreatedAt = c.now()
+
+	eventsJSON, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	sqlStr, args, err := c.sq.
+		Insert("webhooks").
+		Columns("id", "board_id", "url", "secret", "events", "created_at").
+		Values(webhook.Id, webhook.BoardId, webhook.URL, webhook.Secret, eventsJSON, webhook.CreatedAt).
+		ToSql()
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	if _, err := c.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return models.Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (c *Client) selectWebhooks() squirrel.SelectBuilder {
+	return c.sq.
+		Select("id", "board_id", "url", "secret", "events", "created_at").
+		From("webhooks")
+}
+
+func scanWebhook(row interface{ Scan(...any) error }) (models.Webhook, error) {
+	var w models.Webhook
+	var eventsJSON []byte
+	if err := row.Scan(&w.Id, &w.BoardId, &w.URL, &w.Secret, &eventsJSON, &w.CreatedAt); err != nil {
+		return models.Webhook{}, err
+	}
+	if err := json.Unmarshal(eventsJSON, &w.Events); err != nil {
+		return models.Webhook{}, err
+	}
+	return w, nil
+}
+
+// GetWebhooks returns the webhooks of the board, newest first.
+func (c *Client) GetWebhooks(ctx context.Context, boardId string) ([]models.Webhook, error) {
+	return c.getWebhooks(ctx, c.db, boardId)
+}
+
+func (c *Client) getWebhooks(ctx context.Context, q squirrel.QueryerContext, boardId string) ([]models.Webhook, error) {
+	sqlStr, args, err := c.selectWebhooks().
+		Where(squirrel.Eq{"board_id": boardId}).
+		OrderBy("created_at DESC").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetWebhook returns a webhook of the board. It returns ErrWebhookNotFound
+// when the board has no such webhook.
+func (c *Client) GetWebhook(ctx context.Context, boardId, id string) (models.Webhook, error) {
+	if uuid.Validate(id) != nil {
+		return models.Webhook{}, ErrWebhookNotFound
+	}
+	sqlStr, args, err := c.selectWebhooks().
+		Where(squirrel.Eq{"id": id, "board_id": boardId}).
+		ToSql()
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	w, err := scanWebhook(c.db.QueryRowContext(ctx, sqlStr, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Webhook{}, ErrWebhookNotFound
+	}
+	return w, err
+}
+
+// DeleteWebhook removes a webhook of the board along with its deliveries.
+func (c *Client) DeleteWebhook(ctx context.Context, boardId, id string) error {
+	if uuid.Validate(id) != nil {
+		return ErrWebhookNotFound
+	}
+	sqlStr, args, err := c.sq.
+		Delete("webhooks").
+		Where(squirrel.Eq{"id": id, "board_id": boardId}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	res, err := c.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+var deliveryColumns = []string{
+	"id", "webhook_id", "event", "payload", "attempts", "status_code", "error",
+	"next_attempt_at", "last_attempt_at", "delivered_at", "created_at",
+}
+
+func deliveryFields(d *models.WebhookDelivery) []any {
+	return []any{
+		&d.Id, &d.WebhookId, &d.Event, &d.Payload, &d.Attempts, &d.StatusCode, &d.Error,
+		&d.NextAttemptAt, &d.LastAttemptAt, &d.DeliveredAt, &d.CreatedAt,
+	}
+}
+
+// GetWebhookDeliveries returns the latest deliveries of the webhook, newest
+// first.
+func (c *Client) GetWebhookDeliveries(ctx context.Context, webhookId string, limit int) ([]models.WebhookDelivery, error) {
+	sqlStr, args, err := c.sq.
+		Select(deliveryColumns...).
+		From("webhook_deliveries").
+		Where(squirrel.Eq{"webhook_id": webhookId}).
+		OrderBy("created_at DESC").
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(deliveryFields(&d)...); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Redeliver queues a new delivery of the payload of an earlier one, due
+// straight away. It returns ErrWebhookNotFound when the board has no such
+// webhook or delivery.
+func (c *Client) Redeliver(ctx context.Context, boardId, webhookId, deliveryId string) error {
+	if uuid.Validate(webhookId) != nil || uuid.Validate(deliveryId) != nil {
+		return ErrWebhookNotFound
+	}
+	now := c.now()
+	sqlStr, args, err := c.sq.
+		Insert("webhook_deliveries").
+		Columns("id", "webhook_id", "event", "payload", "next_attempt_at", "created_at").
+		Select(squirrel.
+			Select().
+			Column("?::uuid, d.webhook_id, d.event, d.payload, ?::timestamptz, ?::timestamptz", uuid.NewString(), now, now).
+			From("webhook_deliveries d").
+			Join("webhooks w ON w.id = d.webhook_id").
+			Where(squirrel.Eq{"d.id": deliveryId, "w.id": webhookId, "w.board_id": boardId})).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	res, err := c.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ClaimDueDeliveries returns up to limit deliveries which are due, pushing
+// their next attempt back by lease so nobody else picks them up while they
+// are being sent. Deliveries whose result is never recorded are tried again
+// once the lease runs out.
+func (c *Client) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.DueDelivery, error) {
+	now := c.now()
+	due := squirrel.
+		Select("id").
+		From("webhook_deliveries").
+		Where(squirrel.LtOrEq{"next_attempt_at": now}).
+		OrderBy("next_attempt_at").
+		Limit(uint64(limit)).
+		Suffix("FOR UPDATE SKIP LOCKED")
+	dueSQL, dueArgs, err := due.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	returning := make([]string, 0, len(deliveryColumns))
+	for _, col := range deliveryColumns {
+		returning = append(returning, "d."+col)
+	}
+	sqlStr, args, err := c.sq.
+		Update("webhook_deliveries d").
+		Set("next_attempt_at", now.Add(lease)).
+		From("webhooks w").
+		Where("w.id = d.webhook_id").
+		Where("d.id IN ("+dueSQL+")", dueArgs...).
+		Suffix("RETURNING " + strings.Join(returning, ", ") + ", w.url, w.secret").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.DueDelivery{}
+	for rows.Next() {
+		var d models.DueDelivery
+		fields := append(deliveryFields(&d.WebhookDelivery), &d.URL, &d.Secret)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// RecordDeliveryAttempt keeps the outcome of an attempt at the delivery.
+func (c *Client) RecordDeliveryAttempt(ctx context.Context, id string, attempt models.DeliveryAttempt) error {
+	update := c.sq.
+		Update("webhook_deliveries").
+		Set("attempts", squirrel.Expr("attempts + 1")).
+		Set("status_code", attempt.StatusCode).
+		Set("error", attempt.Error).
+		Set("last_attempt_at", attempt.At).
+		Set("next_attempt_at", attempt.NextAttemptAt).
+		Where(squirrel.Eq{"id": id})
+	if attempt.Delivered {
+		update = update.Set("delivered_at", attempt.At)
+	}
+	sqlStr, args, err := update.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = c.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// queueDeliveries queues deliveries of the changes made to the tickets of the
+// board to the webhooks subscribed to them. It runs within the transaction of
+// the change, so they are only sent once the change is saved.
+func (c *Client) queueDeliveries(ctx context.Context, tx *sql.Tx, boardId string, changes []models.TicketChange, now time.Time) error {
+	webhooks, err := c.getWebhooks(ctx, tx, boardId)
+	if err != nil {
+		return err
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	insert := c.sq.
+		Insert("webhook_deliveries").
+		Columns("id", "webhook_id", "event", "payload", "next_attempt_at", "created_at")
+	queued := 0
+	for _, change := range changes {
+		payload, err := json.Marshal(models.NewWebhookPayload(boardId, change, now))
+		if err != nil {
+			return err
+		}
+		for _, w := range webhooks {
+			if !w.Wants(change.Event) {
+				continue
+			}
+			insert = insert.Values(uuid.NewString(), w.Id, change.Event, payload, now, now)
+			queued++
+		}
+	}
+	if queued == 0 {
+		return nil
+	}
+
+	sqlStr, args, err := insert.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, sqlStr, args...)
+	return err
+}
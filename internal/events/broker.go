@@ -0,0 +1,66 @@
+package events
+
+import (
+	"sync"
+)
+
+// Event tells subscribers a board changed.
+type Event struct {
+	BoardId string
+}
+
+// subscriberBuffer is how many events a subscriber can fall behind by. An
+// event already waiting means the subscriber will refresh anyway, so further
+// ones are dropped.
+const subscriberBuffer = 1
+
+// Broker fans out board events to everyone watching the board.
+type Broker struct {
+	mu   sync.RWMutex
+	subs map[string]map[chan Event]struct{} // boardId -> subscribers
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subs: make(map[string]map[chan Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving the events of the board and a
+// function to stop receiving them, which closes the channel.
+func (b *Broker) Subscribe(boardId string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subs[boardId] == nil {
+		b.subs[boardId] = make(map[chan Event]struct{})
+	}
+	b.subs[boardId][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs[boardId], ch)
+			if len(b.subs[boardId]) == 0 {
+				delete(b.subs, boardId)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish sends the event to every subscriber of its board without blocking.
+func (b *Broker) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subs[e.BoardId] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
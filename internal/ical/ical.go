@@ -0,0 +1,184 @@
+// Package ical writes iCalendar (RFC 5545) calendars of to-dos.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentType is the media type of iCalendar feeds.
+const ContentType = "text/calendar; charset=utf-8"
+
+const (
+	// maxLineOctets is the longest a content line may be before it has to be
+	// folded, not counting the line break.
+	maxLineOctets = 75
+
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405Z"
+)
+
+// TodoStatus is the status of a to-do.
+type TodoStatus string
+
+const (
+	StatusNeedsAction TodoStatus = "NEEDS-ACTION"
+	StatusInProcess   TodoStatus = "IN-PROCESS"
+	StatusCompleted   TodoStatus = "COMPLETED"
+)
+
+// Todo is a VTODO component.
+type Todo struct {
+	// UID identifies the to-do across every version of the feed.
+	UID          string
+	Created      time.Time
+	LastModified time.Time
+	// Due is the day the to-do is due, its time of day is ignored.
+	Due         time.Time
+	Summary     string
+	Description string
+	URL         string
+	Status      TodoStatus
+	// Completed is when the to-do was completed, only written for completed
+	// to-dos.
+	Completed time.Time
+	// Priority goes from 1 for the highest to 9 for the lowest, 0 leaves it
+	// undefined.
+	Priority   int
+	Categories []string
+}
+
+// Calendar is a VCALENDAR object holding to-dos.
+type Calendar struct {
+	// ProdId identifies the product which made the calendar.
+	ProdId string
+	// Name is shown by clients subscribing to the calendar.
+	Name  string
+	Todos []Todo
+}
+
+// Write writes the calendar to w. stamp is when the calendar was made.
+func (c Calendar) Write(w io.Writer, stamp time.Time) error {
+	cw := &contentWriter{w: bufio.NewWriter(w)}
+
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:" + Escape(c.ProdId))
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		cw.line("X-WR-CALNAME:" + Escape(c.Name))
+	}
+	for _, t := range c.Todos {
+		t.write(cw, stamp)
+	}
+	cw.line("END:VCALENDAR")
+
+	if cw.err != nil {
+		return cw.err
+	}
+	return cw.w.Flush()
+}
+
+func (t Todo) write(cw *contentWriter, stamp time.Time) {
+	cw.line("BEGIN:VTODO")
+	cw.line("UID:" + Escape(t.UID))
+	cw.line("DTSTAMP:" + formatDateTime(stamp))
+	if !t.Created.IsZero() {
+		cw.line("CREATED:" + formatDateTime(t.Created))
+	}
+	if !t.LastModified.IsZero() {
+		cw.line("LAST-MODIFIED:" + formatDateTime(t.LastModified))
+	}
+	cw.line("DUE;VALUE=DATE:" + t.Due.Format(dateLayout))
+	cw.line("SUMMARY:" + Escape(t.Summary))
+	if t.Description != "" {
+		cw.line("DESCRIPTION:" + Escape(t.Description))
+	}
+	if t.URL != "" {
+		cw.line("URL:" + t.URL)
+	}
+	if t.Status != "" {
+		cw.line("STATUS:" + string(t.Status))
+	}
+	if t.Status == StatusCompleted && !t.Completed.IsZero() {
+		cw.line("COMPLETED:" + formatDateTime(t.Completed))
+	}
+	if t.Priority > 0 {
+		cw.line(fmt.Sprintf("PRIORITY:%d", t.Priority))
+	}
+	if len(t.Categories) > 0 {
+		categories := make([]string, len(t.Categories))
+		for i, c := range t.Categories {
+			categories[i] = Escape(c)
+		}
+		cw.line("CATEGORIES:" + strings.Join(categories, ","))
+	}
+	cw.line("END:VTODO")
+}
+
+func formatDateTime(t time.Time) string {
+	return t.UTC().Format(dateTimeLayout)
+}
+
+// escaper escapes the characters of TEXT values which have a meaning of their
+// own, line breaks are written as \n.
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	`;`, `\;`,
+	`,`, `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// Escape escapes s for use as a TEXT value.
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// contentWriter writes content lines, folding the long ones. It keeps the
+// first error it runs into and writes nothing after it.
+type contentWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (cw *contentWriter) line(l string) {
+	if cw.err != nil {
+		return
+	}
+	_, cw.err = cw.w.WriteString(Fold(l) + "\r\n")
+}
+
+// Fold splits a content line longer than 75 octets into several, each
+// continued line starting with a space. Lines are never split within a UTF-8
+// sequence.
+func Fold(l string) string {
+	if len(l) <= maxLineOctets {
+		return l
+	}
+
+	var b strings.Builder
+	limit := maxLineOctets
+	n := 0
+	for _, r := range l {
+		size := utf8.RuneLen(r)
+		if size < 0 {
+			size = len(string(utf8.RuneError))
+		}
+		if n+size > limit {
+			b.WriteString("\r\n ")
+			// The leading space counts towards the length of the line.
+			limit = maxLineOctets - 1
+			n = 0
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	return b.String()
+}
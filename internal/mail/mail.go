@@ -0,0 +1,35 @@
+// Package mail sends emails to users.
+package mail
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// LogMailer logs who emails would go to instead of sending them, for
+// development without a mail server. The body is left out of the log as it
+// can hold secrets such as password reset links.
+type LogMailer struct {
+	log *slog.Logger
+}
+
+func NewLogMailer(log *slog.Logger) *LogMailer {
+	return &LogMailer{log: log}
+}
+
+func (l *LogMailer) Send(ctx context.Context, m Message) error {
+	l.log.InfoContext(ctx, "Sending email", "to", m.To, "subject", m.Subject)
+	return nil
+}
@@ -0,0 +1,153 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// sendTimeout bounds sending an email when the context has no deadline.
+const sendTimeout = 30 * time.Second
+
+// implicitTLSPort is the submission port speaking TLS from the start, other
+// ports upgrade with STARTTLS.
+const implicitTLSPort = "465"
+
+// SMTPMailer sends emails through an SMTP server, over TLS. Credentials are
+// only sent once the connection is encrypted.
+type SMTPMailer struct {
+	host string
+	port string
+	user string
+	pass string
+	from string
+}
+
+func NewSMTPMailer(host, port, user, pass, from string) *SMTPMailer {
+	return &SMTPMailer{
+		host: host,
+		port: port,
+		user: user,
+		pass: pass,
+		from: from,
+	}
+}
+
+func (s *SMTPMailer) Send(ctx context.Context, m Message) error {
+	from, err := netmail.ParseAddress(s.from)
+	if err != nil {
+		return fmt.Errorf("parsing sender: %w", err)
+	}
+	to, err := netmail.ParseAddress(m.To)
+	if err != nil {
+		return fmt.Errorf("parsing recipient: %w", err)
+	}
+	msg, err := message(from, to, m)
+	if err != nil {
+		return err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(sendTimeout)
+	}
+
+	d := net.Dialer{Deadline: deadline}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(s.host, s.port))
+	if err != nil {
+		return fmt.Errorf("dialing smtp server: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	// Cancelling the context aborts the exchange with the server.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	tlsConfig := &tls.Config{ServerName: s.host}
+	if s.port == implicitTLSPort {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return fmt.Errorf("greeting smtp server: %w", err)
+	}
+	defer c.Close()
+
+	if s.port != implicitTLSPort {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starting tls: %w", err)
+		}
+	}
+
+	if s.user != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.user, s.pass, s.host)); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("setting sender: %w", err)
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("setting recipient: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("starting data: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("writing message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+
+	return c.Quit()
+}
+
+// message renders the email with its headers. The subject is checked for
+// line breaks so it cannot add headers of its own, the addresses are already
+// parsed.
+func message(from, to *netmail.Address, m Message) ([]byte, error) {
+	if strings.ContainsAny(m.Subject, "\r\n") {
+		return nil, errors.New("subject contains a line break")
+	}
+
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", rand.Text(), domain)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	b.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(m.Body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	return []byte(b.String()), nil
+}
@@ -10,11 +10,15 @@ import (
 )
 
 type Metrics struct {
-	SSENotificationConnections prometheus.Gauge
-	ActiveUsers                prometheus.Gauge
-	ActiveInstances            prometheus.Gauge
-	HTTPRequestsTotal          *prometheus.CounterVec
-	HTTPRequestDuration        *prometheus.HistogramVec
+	SSENotificationConnections   prometheus.Gauge
+	SSEUsersByConnections        *prometheus.GaugeVec
+	SSEDroppedNotificationsTotal prometheus.Counter
+	ActiveUsers                  prometheus.Gauge
+	ActiveInstances              prometheus.Gauge
+	HTTPRequestsTotal            *prometheus.CounterVec
+	HTTPRequestDuration          *prometheus.HistogramVec
+	CSRFFailuresTotal            prometheus.Counter
+	WebhookDeliveryAttemptsTotal *prometheus.CounterVec
 }
 
 const (
@@ -28,6 +32,19 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 			Name:      "sse_active_notification_connections",
 			Help:      "Number of open SSE notification connections",
 		}),
+		SSEUsersByConnections: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespaceName,
+				Name:      "sse_notification_users_by_connections",
+				Help:      "Number of connected users by how many SSE notification connections they have open",
+			},
+			[]string{"connections"},
+		),
+		SSEDroppedNotificationsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespaceName,
+			Name:      "sse_dropped_notifications_total",
+			Help:      "Number of notifications not sent to a connection which fell too far behind",
+		}),
 		ActiveUsers: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespaceName,
 			Name:      "active_users",
@@ -55,15 +72,32 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 			},
 			[]string{"path", "method"},
 		),
+		CSRFFailuresTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespaceName,
+			Name:      "csrf_failures_total",
+			Help:      "Number of state changing requests rejected for a missing or invalid CSRF token",
+		}),
+		WebhookDeliveryAttemptsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespaceName,
+				Name:      "webhook_delivery_attempts_total",
+				Help:      "Number of webhook delivery attempts by outcome",
+			},
+			[]string{"outcome"},
+		),
 	}
 
 	// Register all metrics
 	reg.MustRegister(
 		m.SSENotificationConnections,
+		m.SSEUsersByConnections,
+		m.SSEDroppedNotificationsTotal,
 		m.ActiveUsers,
 		m.ActiveInstances,
 		m.HTTPRequestsTotal,
 		m.HTTPRequestDuration,
+		m.CSRFFailuresTotal,
+		m.WebhookDeliveryAttemptsTotal,
 	)
 
 	return m
@@ -110,6 +144,12 @@ func HTTPMiddleware(m *Metrics, next http.Handler) http.Handler {
 			return
 		}
 
+		// Prefer the matched route so ids in paths do not blow up the
+		// cardinality of the path label.
+		if i := strings.Index(r.Pattern, "/"); i >= 0 {
+			path = r.Pattern[i:]
+		}
+
 		method := r.Method
 
 		m.HTTPRequestDuration.WithLabelValues(path, method).Observe(duration)
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/metrics"
+	"github.com/alexedwards/scs/v2"
+)
+
+const (
+	// CSRFHeader is the header htmx sends the token in.
+	CSRFHeader = "X-CSRF-Token"
+	// CSRFFormField is checked when the header is missing, for plain forms.
+	CSRFFormField = "csrf_token"
+
+	csrfSessionKey = "csrf_token"
+)
+
+// maxFormBytes caps the size of plain form bodies read for their token, the
+// largest form of the app. Handlers limit smaller forms further when the
+// token comes in the header.
+const maxFormBytes = 64 << 10
+
+type csrfContextKey struct{}
+
+// CSRF makes sure every state changing request carries the token of its
+// session. Tokens are issued per session and made available to templates
+// via CSRFToken. Safe requests to paths under any of the read-only prefixes
+// are not issued one, so public read-only pages do not start a session. It
+// has to run inside the session middleware.
+func CSRF(log *slog.Logger, sm *scs.SessionManager, m *metrics.Metrics, next http.Handler, readOnly ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		safe := false
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			safe = true
+		}
+
+		token := sm.GetString(r.Context(), csrfSessionKey)
+		if token == "" {
+			if safe && slices.ContainsFunc(readOnly, func(prefix string) bool {
+				return strings.HasPrefix(r.URL.Path, prefix)
+			}) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			token = rand.Text()
+			sm.Put(r.Context(), csrfSessionKey, token)
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), csrfContextKey{}, token))
+
+		if safe {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sent := r.Header.Get(CSRFHeader)
+		if sent == "" {
+			r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+			if err := r.ParseForm(); err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "Invalid form", http.StatusBadRequest)
+				return
+			}
+			sent = r.PostForm.Get(CSRFFormField)
+		}
+
+		if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			log.Warn("CSRF check failed",
+				"method", r.Method,
+				"uri", r.RequestURI,
+				"tokenSent", sent != "",
+			)
+			m.CSRFFailuresTotal.Inc()
+			http.Error(w, "Invalid or missing CSRF token, reload the page and try again", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CSRFToken returns the token of the session of the request, or an empty
+// string when the request did not go through CSRF.
+func CSRFToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfContextKey{}).(string)
+	return token
+}
+
+// CSRFHeaders returns the hx-headers value which makes htmx send the token
+// with every request.
+func CSRFHeaders(r *http.Request) string {
+	headers, _ := json.Marshal(map[string]string{CSRFHeader: CSRFToken(r)})
+	return string(headers)
+}
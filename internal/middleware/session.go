@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/db"
+	"github.com/alexedwards/scs/v2"
+)
+
+type sessionGenerationGetter interface {
+	GetSessionGeneration(ctx context.Context, accountId string) (int, error)
+}
+
+// RevokedSessions logs out sessions of accounts which revoked them since, by
+// resetting the password, and of accounts which no longer exist. Sessions
+// logged into an account keep the session generation of the account at the
+// time, they are destroyed once it moved on. It has to run inside the session
+// middleware.
+func RevokedSessions(log *slog.Logger, sm *scs.SessionManager, accounts sessionGenerationGetter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accountId := sm.GetString(r.Context(), "account")
+		if accountId == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		generation, err := accounts.GetSessionGeneration(r.Context(), accountId)
+		if err != nil && !errors.Is(err, db.ErrAccountNotFound) {
+			log.Error("Error fetching session generation", "accountId", accountId, "error", err.Error())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if err != nil || generation != sm.GetInt(r.Context(), "generation") {
+			log.Info("Logging out revoked session", "accountId", accountId)
+			if err := sm.Destroy(r.Context()); err != nil {
+				log.Error("Error destroying session", "accountId", accountId, "error", err.Error())
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
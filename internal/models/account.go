@@ -0,0 +1,97 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 32
+	MaxEmailLength    = 254
+	MinPasswordLength = 8
+	// MaxPasswordLength is the most bcrypt hashes, longer passwords would be
+	// cut short without a word.
+	MaxPasswordLength = 72
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Account is someone who registered, it owns a board which is kept for as
+// long as the account is.
+type Account struct {
+	Id string
+	// Username and Email are both kept in lower case, either logs in.
+	Username     string
+	Email        string
+	PasswordHash string `json:"-"`
+	// SessionGeneration is kept in the sessions logged into the account, it
+	// goes up when those sessions are revoked.
+	SessionGeneration int `json:"-"`
+	// BoardId is the id of the board owned by the account, empty when it has
+	// none.
+	BoardId   string
+	CreatedAt time.Time
+}
+
+// NormalizeLogin returns the username or email as kept in accounts.
+func NormalizeLogin(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// ValidateRegistration checks the fields a new account is registered with. It
+// returns ValidationErrors when any of them are invalid and nil otherwise.
+func ValidateRegistration(username, email, password string) error {
+	errs := ValidationErrors{}
+
+	switch l := utf8.RuneCountInString(username); {
+	case username == "":
+		errs["username"] = "Username is required"
+	case l < MinUsernameLength || l > MaxUsernameLength:
+		errs["username"] = fmt.Sprintf("Username must be %d to %d characters", MinUsernameLength, MaxUsernameLength)
+	case !usernamePattern.MatchString(username):
+		errs["username"] = "Username may only hold letters, digits, _ and -"
+	}
+
+	addr, err := mail.ParseAddress(email)
+	switch {
+	case email == "":
+		errs["email"] = "Email is required"
+	case len(email) > MaxEmailLength:
+		errs["email"] = fmt.Sprintf("Email must be at most %d characters", MaxEmailLength)
+	case err != nil || addr.Address != email:
+		errs["email"] = "Email must be a valid email address"
+	}
+
+	if msg := passwordError(password); msg != "" {
+		errs["password"] = msg
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidatePassword checks a new password. It returns ValidationErrors when it
+// is invalid and nil otherwise.
+func ValidatePassword(password string) error {
+	if msg := passwordError(password); msg != "" {
+		return ValidationErrors{"password": msg}
+	}
+	return nil
+}
+
+func passwordError(password string) string {
+	switch {
+	case utf8.RuneCountInString(password) < MinPasswordLength:
+		return fmt.Sprintf("Password must be at least %d characters", MinPasswordLength)
+	case len(password) > MaxPasswordLength:
+		return fmt.Sprintf("Password must be at most %d bytes", MaxPasswordLength)
+	}
+	return ""
+}
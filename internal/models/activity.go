@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// ActivityKind names a kind of activity on a board.
+type ActivityKind string
+
+const (
+	ActivityCreated   ActivityKind = "created"
+	ActivityMoved     ActivityKind = "moved"
+	ActivityCompleted ActivityKind = "completed"
+	ActivityDeleted   ActivityKind = "deleted"
+)
+
+func (k ActivityKind) String() string {
+	return string(k)
+}
+
+// Activity is something which happened to a ticket of a board, as followed
+// through the activity feed. The key and title are those the ticket had at
+// the time.
+type Activity struct {
+	Id        string
+	BoardId   string
+	TicketId  string
+	TicketKey string
+	Title     string
+	Kind      ActivityKind
+	// From and To are the statuses a moved or completed ticket went between.
+	From      Status
+	To        Status
+	CreatedAt time.Time
+}
+
+// NewActivity returns the activity a change to a ticket amounts to, or false
+// for changes which are not followed, such as edits. Moving a ticket to done
+// completes it.
+func NewActivity(boardId string, change TicketChange, at time.Time) (Activity, bool) {
+	a := Activity{
+		BoardId:   boardId,
+		TicketId:  change.Ticket.Id,
+		TicketKey: change.Ticket.Key,
+		Title:     change.Ticket.Title,
+		CreatedAt: at,
+	}
+
+	switch change.Event {
+	case TicketCreated:
+		a.Kind = ActivityCreated
+		a.To = change.Ticket.Status
+	case TicketMoved:
+		a.Kind = ActivityMoved
+		if change.Ticket.Status == StatusDone {
+			a.Kind = ActivityCompleted
+		}
+		a.From = change.From
+		a.To = change.Ticket.Status
+	case TicketDeleted:
+		a.Kind = ActivityDeleted
+		a.From = change.Ticket.Status
+	default:
+		return Activity{}, false
+	}
+	return a, true
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// BulkAction is what a bulk operation does to each of its tickets.
+type BulkAction string
+
+const (
+	BulkMove    BulkAction = "move"
+	BulkDelete  BulkAction = "delete"
+	BulkLabel   BulkAction = "label"
+	BulkArchive BulkAction = "archive"
+)
+
+// BulkOperation applies one action to many tickets at once. Status is only
+// used when moving and Label only when labelling.
+type BulkOperation struct {
+	Action BulkAction
+	Status Status
+	Label  string
+}
+
+// Validate checks that the operation has everything its action needs.
+func (op BulkOperation) Validate() error {
+	errs := ValidationErrors{}
+
+	switch op.Action {
+	case BulkMove:
+		if !op.Status.Valid() {
+			errs["status"] = "Pick a status to move the tickets to"
+		}
+	case BulkLabel:
+		label := strings.TrimSpace(op.Label)
+		if label == "" {
+			errs["label"] = "Label is required"
+		} else if len(label) > MaxLabelLength {
+			errs["label"] = fmt.Sprintf("Labels must be at most %d characters", MaxLabelLength)
+		}
+	case BulkDelete, BulkArchive:
+	default:
+		errs["action"] = "Unknown action"
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ApplyBulk applies the operation to the tickets with the given ids. It
+// returns the updated tickets along with the affected ones, or an error
+// when a ticket would end up invalid, in which case nothing should be saved.
+// Ids without a ticket are ignored.
+func ApplyBulk(tickets []Ticket, ids []string, op BulkOperation, now time.Time) ([]Ticket, []Ticket, error) {
+	updated := make([]Ticket, 0, len(tickets))
+	affected := []Ticket{}
+	for _, t := range tickets {
+		if !slices.Contains(ids, t.Id) {
+			updated = append(updated, t)
+			continue
+		}
+
+		switch op.Action {
+		case BulkMove:
+			t.MoveTo(op.Status, now)
+		case BulkLabel:
+			label := strings.TrimSpace(op.Label)
+			if !slices.ContainsFunc(t.Labels, func(l string) bool { return strings.EqualFold(l, label) }) {
+				t.Labels = append(slices.Clone(t.Labels), label)
+				t.LastUpdatedAt = now
+			}
+		case BulkArchive:
+			if !t.Archived() {
+				t.Archive(now)
+			}
+		}
+
+		if err := t.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("ticket %s: %w", t.Key, err)
+		}
+
+		affected = append(affected, t)
+		if op.Action != BulkDelete {
+			updated = append(updated, t)
+		}
+	}
+
+	return updated, affected, nil
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// TicketEvent names a kind of change made to a ticket.
+type TicketEvent string
+
+const (
+	TicketCreated TicketEvent = "ticket.created"
+	TicketMoved   TicketEvent = "ticket.moved"
+	TicketEdited  TicketEvent = "ticket.edited"
+	TicketDeleted TicketEvent = "ticket.deleted"
+)
+
+// TicketEvents lists all ticket events.
+var TicketEvents = []TicketEvent{
+	TicketCreated,
+	TicketMoved,
+	TicketEdited,
+	TicketDeleted,
+}
+
+func (e TicketEvent) String() string {
+	return string(e)
+}
+
+// Valid reports whether the event is one of the known ticket events.
+func (e TicketEvent) Valid() bool {
+	return slices.Contains(TicketEvents, e)
+}
+
+// TicketChange is a single change made to a ticket of a board.
+type TicketChange struct {
+	Event TicketEvent
+	// Ticket is the ticket after the change, or before it when it was
+	// deleted.
+	Ticket Ticket
+	// From is the status the ticket was moved from.
+	From Status
+}
+
+// DiffTickets returns the changes which turn the tickets old into new, in the
+// order of new followed by the deleted tickets. A ticket which was both moved
+// and edited gives a change of each.
+func DiffTickets(old, new []Ticket) []TicketChange {
+	before := make(map[string]Ticket, len(old))
+	for _, t := range old {
+		before[t.Id] = t
+	}
+
+	changes := []TicketChange{}
+	for _, t := range new {
+		prev, ok := before[t.Id]
+		delete(before, t.Id)
+		if !ok {
+			changes = append(changes, TicketChange{Event: TicketCreated, Ticket: t})
+			continue
+		}
+		if prev.Status != t.Status {
+			changes = append(changes, TicketChange{Event: TicketMoved, Ticket: t, From: prev.Status})
+		}
+		if edited(prev, t) {
+			changes = append(changes, TicketChange{Event: TicketEdited, Ticket: t})
+		}
+	}
+
+	for _, t := range old {
+		if _, ok := before[t.Id]; ok {
+			changes = append(changes, TicketChange{Event: TicketDeleted, Ticket: t})
+		}
+	}
+	return changes
+}
+
+// edited reports whether any of the fields users edit differ between the two
+// versions of a ticket.
+func edited(a, b Ticket) bool {
+	return a.Title != b.Title ||
+		a.Description != b.Description ||
+		!slices.Equal(a.Labels, b.Labels) ||
+		a.Priority != b.Priority ||
+		a.Assignee != b.Assignee ||
+		a.Estimate != b.Estimate ||
+		!equalTimes(a.DueAt, b.DueAt) ||
+		!equalTimes(a.ArchivedAt, b.ArchivedAt)
+}
+
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// DueDateLayout is how due dates are written in forms and the API.
+const DueDateLayout = "2006-01-02"
+
+// ParseDueDate parses a due date written as DueDateLayout, an empty string
+// means no due date.
+func ParseDueDate(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	due, err := time.ParseInLocation(DueDateLayout, s, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	return &due, nil
+}
+
+// DueDate returns the due date of the ticket written as DueDateLayout, or an
+// empty string when it has none.
+func (t Ticket) DueDate() string {
+	if t.DueAt == nil {
+		return ""
+	}
+	return t.DueAt.Format(DueDateLayout)
+}
+
+// Overdue reports whether the due date of the ticket passed without it being
+// done.
+func (t Ticket) Overdue(now time.Time) bool {
+	if t.DueAt == nil || t.Status == StatusDone {
+		return false
+	}
+	return !now.Before(t.DueAt.AddDate(0, 0, 1))
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// GitHubIssueRef returns the ExternalRef of a GitHub issue of the repository,
+// given as owner/name.
+func GitHubIssueRef(repo string, number int) string {
+	return fmt.Sprintf("github:%s#%d", repo, number)
+}
+
+// SyncExternalTicket brings the tickets in line with incoming, a ticket
+// mirrored from another system. When no ticket has the ExternalRef of
+// incoming, incoming is added, unless it is already done, there being nothing
+// to track then. Otherwise the ticket is moved to the status of incoming when
+// move is set and left as it is when not. It returns the updated tickets, the
+// ticket as it ends up and what was done to it, which is empty when nothing
+// was.
+func SyncExternalTicket(tickets []Ticket, incoming Ticket, move bool, now time.Time) ([]Ticket, Ticket, TicketEvent) {
+	i := slices.IndexFunc(tickets, func(t Ticket) bool { return t.ExternalRef == incoming.ExternalRef })
+	if i < 0 {
+		if incoming.Status == StatusDone {
+			return tickets, Ticket{}, ""
+		}
+		return append(slices.Clone(tickets), incoming), incoming, TicketCreated
+	}
+
+	existing := tickets[i]
+	if !move || existing.Status == incoming.Status {
+		return tickets, existing, ""
+	}
+
+	existing.MoveTo(incoming.Status, now)
+	if existing.Archived() && incoming.Status != StatusDone {
+		// Work starting over on the ticket belongs back on the board.
+		existing.Unarchive(now)
+	}
+	updated := slices.Clone(tickets)
+	updated[i] = existing
+	return updated, existing, TicketMoved
+}
+
+// DeletedExternalRefs returns the ExternalRef of every mirrored ticket in old
+// which is missing from new.
+func DeletedExternalRefs(old, new []Ticket) []string {
+	refs := []string{}
+	for _, t := range old {
+		if t.ExternalRef == "" {
+			continue
+		}
+		if !slices.ContainsFunc(new, func(n Ticket) bool { return n.ExternalRef == t.ExternalRef }) {
+			refs = append(refs, t.ExternalRef)
+		}
+	}
+	return refs
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// TicketFilter narrows down a list of tickets, zero valued fields match
+// everything. Archived tickets only match filters for archived tickets.
+type TicketFilter struct {
+	Archived bool
+	// Query is matched case insensitively against the key, title,
+	// description and labels of the ticket.
+	Query    string
+	Status   Status
+	Label    string
+	Priority *Priority
+	Assignee string
+}
+
+// ParseTicketFilter reads a filter from the q, status, label, priority and
+// assignee query parameters. A priority of "none" only matches tickets
+// without a priority.
+func ParseTicketFilter(query url.Values) TicketFilter {
+	f := TicketFilter{
+		Query:    strings.TrimSpace(query.Get("q")),
+		Status:   Status(query.Get("status")),
+		Label:    strings.TrimSpace(query.Get("label")),
+		Assignee: strings.TrimSpace(query.Get("assignee")),
+	}
+
+	if p := query.Get("priority"); p != "" {
+		priority := Priority(p)
+		if p == "none" {
+			priority = PriorityNone
+		}
+		f.Priority = &priority
+	}
+
+	return f
+}
+
+// IsEmpty reports whether the filter matches every ticket on the board.
+func (f TicketFilter) IsEmpty() bool {
+	return f.Query == "" &&
+		f.Status == "" &&
+		f.Label == "" &&
+		f.Priority == nil &&
+		f.Assignee == ""
+}
+
+// Matches reports whether the ticket passes the filter.
+func (f TicketFilter) Matches(t Ticket) bool {
+	if t.Archived() != f.Archived {
+		return false
+	}
+
+	if f.Status != "" && t.Status != f.Status {
+		return false
+	}
+
+	if f.Label != "" && !slices.ContainsFunc(t.Labels, func(l string) bool {
+		return strings.EqualFold(l, f.Label)
+	}) {
+		return false
+	}
+
+	if f.Priority != nil && t.Priority != *f.Priority {
+		return false
+	}
+
+	if f.Assignee != "" && !strings.EqualFold(t.Assignee, f.Assignee) {
+		return false
+	}
+
+	if f.Query != "" {
+		q := strings.ToLower(strings.TrimSpace(f.Query))
+		fields := append([]string{t.Key, t.Title, t.Description}, t.Labels...)
+		if !slices.ContainsFunc(fields, func(s string) bool {
+			return strings.Contains(strings.ToLower(s), q)
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FilterTickets returns the tickets which pass the filter, keeping their
+// order.
+func FilterTickets(tickets []Ticket, f TicketFilter) []Ticket {
+	filtered := []Ticket{}
+	for _, t := range tickets {
+		if f.Matches(t) {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultKeyPrefix   = "LB"
+	MaxKeyPrefixLength = 10
+)
+
+var (
+	keyPrefixRegex = regexp.MustCompile(`^[A-Z][A-Z0-9]*$`)
+	keyRegex       = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9]*)-([0-9]+)$`)
+)
+
+// FormatKey builds a human readable ticket key, e.g. LB-42.
+func FormatKey(prefix string, number int) string {
+	return fmt.Sprintf("%s-%d", prefix, number)
+}
+
+// ParseKey splits a ticket key into its prefix and number. The prefix is
+// upper cased so keys can be typed in any case.
+func ParseKey(key string) (prefix string, number int, ok bool) {
+	m := keyRegex.FindStringSubmatch(strings.TrimSpace(key))
+	if m == nil {
+		return "", 0, false
+	}
+
+	number, err := strconv.Atoi(m[2])
+	if err != nil || number <= 0 {
+		return "", 0, false
+	}
+
+	return strings.ToUpper(m[1]), number, true
+}
+
+// ValidateKeyPrefix checks a board key prefix is short, upper case and
+// alphanumeric, starting with a letter.
+func ValidateKeyPrefix(prefix string) error {
+	errs := ValidationErrors{}
+
+	switch {
+	case prefix == "":
+		errs["key_prefix"] = "Key prefix is required"
+	case len(prefix) > MaxKeyPrefixLength:
+		errs["key_prefix"] = fmt.Sprintf("Key prefix must be at most %d characters", MaxKeyPrefixLength)
+	case !keyPrefixRegex.MatchString(prefix):
+		errs["key_prefix"] = "Key prefix must be upper case letters and digits, starting with a letter"
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
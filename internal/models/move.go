@@ -0,0 +1,84 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// MoveTicket moves the ticket with the given id into the status and lane of
+// the board, placing it at index among the tickets already there. It returns
+// the updated tickets and the moved ticket, or false if there is no ticket
+// with that id.
+func MoveTicket(
+	tickets []Ticket,
+	id string,
+	status Status,
+	swimlanes SwimlaneField,
+	lane string,
+	index int,
+	now time.Time,
+) ([]Ticket, Ticket, bool) {
+	from := slices.IndexFunc(tickets, func(t Ticket) bool { return t.Id == id })
+	if from < 0 {
+		return tickets, Ticket{}, false
+	}
+
+	moved := tickets[from]
+	moved.MoveTo(status, now)
+	if swimlanes.LaneOf(moved) != lane {
+		swimlanes.SetLane(&moved, lane)
+	}
+	moved.LastUpdatedAt = now
+
+	rest := slices.Delete(slices.Clone(tickets), from, from+1)
+
+	// Find where the index-th ticket of the destination stack sits in the
+	// full list, appending after the last one when moving past the end.
+	to := len(rest)
+	seen := 0
+	for i, t := range rest {
+		if t.Status != status || swimlanes.LaneOf(t) != lane {
+			continue
+		}
+		if seen == index {
+			to = i
+			break
+		}
+		seen++
+		to = i + 1
+	}
+
+	return slices.Insert(rest, to, moved), moved, true
+}
+
+// VisibleMoveIndex translates the index a ticket was dropped at on a board,
+// where archived or filtered out tickets are not shown and the order may be
+// sorted, into the index MoveTicket expects. The ticket is placed in front of the ticket it was
+// dropped in front of, or right after the last shown one.
+func VisibleMoveIndex(
+	tickets []Ticket,
+	id string,
+	status Status,
+	swimlanes SwimlaneField,
+	lane string,
+	f TicketFilter,
+	s TicketSort,
+	index int,
+) int {
+	stack := []Ticket{}
+	for _, t := range tickets {
+		if t.Id != id && t.Status == status && swimlanes.LaneOf(t) == lane {
+			stack = append(stack, t)
+		}
+	}
+
+	visible := SortTickets(FilterTickets(stack, f), s)
+	if len(visible) == 0 {
+		return 0
+	}
+
+	if index < len(visible) {
+		return slices.IndexFunc(stack, func(t Ticket) bool { return t.Id == visible[index].Id })
+	}
+	return slices.IndexFunc(stack, func(t Ticket) bool { return t.Id == visible[len(visible)-1].Id }) + 1
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/util"
+)
+
+// Notification is a message kept in the inbox of a user.
+type Notification struct {
+	Id        string
+	UserId    string
+	Message   util.UiMessage
+	CreatedAt time.Time
+	ReadAt    *time.Time
+}
+
+// Read reports whether the user marked the notification as read.
+func (n Notification) Read() bool {
+	return n.ReadAt != nil
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// TicketPatch holds the fields of a partial ticket update, nil fields are
+// left untouched.
+type TicketPatch struct {
+	Title       *string
+	Description *string
+	Status      *Status
+	Labels      *[]string
+	Priority    *Priority
+	Assignee    *string
+	Estimate    *int
+	// DueAt sets the due date, a zero time clears it.
+	DueAt *time.Time
+}
+
+// IsEmpty reports whether the patch does not change anything.
+func (p TicketPatch) IsEmpty() bool {
+	return p.Title == nil &&
+		p.Description == nil &&
+		p.Status == nil &&
+		p.Labels == nil &&
+		p.Priority == nil &&
+		p.Assignee == nil &&
+		p.Estimate == nil &&
+		p.DueAt == nil
+}
+
+// Apply updates the supplied fields of the ticket and bumps LastUpdatedAt.
+// Status changes are recorded as transitions.
+func (p TicketPatch) Apply(t *Ticket, now time.Time) {
+	if p.Title != nil {
+		t.Title = *p.Title
+	}
+	if p.Description != nil {
+		t.Description = *p.Description
+	}
+	if p.Status != nil {
+		t.MoveTo(*p.Status, now)
+	}
+	if p.Labels != nil {
+		t.Labels = *p.Labels
+	}
+	if p.Priority != nil {
+		t.Priority = *p.Priority
+	}
+	if p.Assignee != nil {
+		t.Assignee = *p.Assignee
+	}
+	if p.Estimate != nil {
+		t.Estimate = *p.Estimate
+	}
+	if p.DueAt != nil {
+		if p.DueAt.IsZero() {
+			t.DueAt = nil
+		} else {
+			due := *p.DueAt
+			t.DueAt = &due
+		}
+	}
+	t.LastUpdatedAt = now
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// DefaultArchiveAfterDays is how long done tickets stay on new boards.
+	DefaultArchiveAfterDays = 14
+	MaxArchiveAfterDays     = 365
+)
+
+// BoardSettings are the settings of a board the user can change.
+type BoardSettings struct {
+	KeyPrefix string
+	// ArchiveAfterDays is how many days done tickets stay on the board
+	// without being touched before they are archived automatically, zero
+	// turns automatic archiving off.
+	ArchiveAfterDays int
+}
+
+// Settings returns the current settings of the board.
+func (b Board) Settings() BoardSettings {
+	return BoardSettings{
+		KeyPrefix:        b.KeyPrefix,
+		ArchiveAfterDays: b.ArchiveAfterDays,
+	}
+}
+
+// Validate checks all of the settings.
+func (s BoardSettings) Validate() error {
+	errs := ValidationErrors{}
+	errors.As(ValidateKeyPrefix(s.KeyPrefix), &errs)
+
+	if s.ArchiveAfterDays < 0 || s.ArchiveAfterDays > MaxArchiveAfterDays {
+		errs["archive_after_days"] = fmt.Sprintf("Must be between 0 and %d days", MaxArchiveAfterDays)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
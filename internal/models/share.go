@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// ShareLink gives read-only access to a board to anyone holding its token,
+// until it is revoked.
+type ShareLink struct {
+	Id        string
+	BoardId   string
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// Revoked reports whether the link was revoked.
+func (l ShareLink) Revoked() bool {
+	return l.RevokedAt != nil
+}
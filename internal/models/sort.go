@@ -0,0 +1,56 @@
+package models
+
+import (
+	"cmp"
+	"slices"
+)
+
+// TicketSort is the order tickets are shown in within a column.
+type TicketSort string
+
+const (
+	// SortManual keeps the order the tickets were dragged into.
+	SortManual   TicketSort = ""
+	SortUpdated  TicketSort = "updated"
+	SortCreated  TicketSort = "created"
+	SortPriority TicketSort = "priority"
+)
+
+func (s TicketSort) String() string {
+	return string(s)
+}
+
+// ParseTicketSort parses a sort order, falling back to SortManual for
+// anything unknown.
+func ParseTicketSort(s string) TicketSort {
+	switch ts := TicketSort(s); ts {
+	case SortUpdated, SortCreated, SortPriority:
+		return ts
+	}
+	return SortManual
+}
+
+// SortTickets returns the tickets in the given order, most recent or most
+// pressing first. Ties keep their manual order.
+func SortTickets(tickets []Ticket, s TicketSort) []Ticket {
+	if s == SortManual {
+		return tickets
+	}
+
+	sorted := slices.Clone(tickets)
+	slices.SortStableFunc(sorted, func(a, b Ticket) int {
+		switch s {
+		case SortUpdated:
+			return b.LastUpdatedAt.Compare(a.LastUpdatedAt)
+		case SortCreated:
+			return b.CreatedAt.Compare(a.CreatedAt)
+		case SortPriority:
+			return cmp.Compare(
+				slices.Index(Priorities, a.Priority),
+				slices.Index(Priorities, b.Priority),
+			)
+		}
+		return 0
+	})
+	return sorted
+}
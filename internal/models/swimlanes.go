@@ -0,0 +1,147 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// SwimlaneField is the ticket field the board is grouped by horizontally.
+type SwimlaneField string
+
+const (
+	SwimlaneNone     SwimlaneField = ""
+	SwimlaneLabel    SwimlaneField = "label"
+	SwimlanePriority SwimlaneField = "priority"
+	SwimlaneAssignee SwimlaneField = "assignee"
+)
+
+func (f SwimlaneField) String() string {
+	return string(f)
+}
+
+// ParseSwimlaneField returns the matching field, falling back to SwimlaneNone
+// for anything unknown.
+func ParseSwimlaneField(s string) SwimlaneField {
+	switch f := SwimlaneField(s); f {
+	case SwimlaneLabel, SwimlanePriority, SwimlaneAssignee:
+		return f
+	}
+	return SwimlaneNone
+}
+
+// LaneOf returns the lane a ticket belongs to. Tickets are grouped by their
+// first label when grouping by label.
+func (f SwimlaneField) LaneOf(t Ticket) string {
+	switch f {
+	case SwimlaneLabel:
+		if len(t.Labels) == 0 {
+			return ""
+		}
+		return t.Labels[0]
+	case SwimlanePriority:
+		return t.Priority.String()
+	case SwimlaneAssignee:
+		return t.Assignee
+	}
+	return ""
+}
+
+// SetLane updates the grouping field of the ticket so it lands in the given
+// lane. For labels the label of the lane the ticket was in gets replaced.
+func (f SwimlaneField) SetLane(t *Ticket, lane string) {
+	switch f {
+	case SwimlaneLabel:
+		labels := t.Labels
+		if len(labels) > 0 {
+			labels = labels[1:]
+		}
+		labels = slices.DeleteFunc(slices.Clone(labels), func(l string) bool {
+			return l == lane
+		})
+		if lane != "" {
+			labels = append([]string{lane}, labels...)
+		}
+		t.Labels = labels
+	case SwimlanePriority:
+		t.Priority = Priority(lane)
+	case SwimlaneAssignee:
+		t.Assignee = lane
+	}
+}
+
+// LaneTitle returns a human readable title for a lane.
+func (f SwimlaneField) LaneTitle(lane string) string {
+	if lane != "" {
+		return lane
+	}
+	switch f {
+	case SwimlaneLabel:
+		return "No label"
+	case SwimlanePriority:
+		return "No priority"
+	case SwimlaneAssignee:
+		return "Unassigned"
+	}
+	return ""
+}
+
+// Swimlane is one horizontal row of the board.
+type Swimlane struct {
+	Name       string
+	Todo       []Ticket
+	InProgress []Ticket
+	Done       []Ticket
+}
+
+// SplitIntoSwimlanes groups tickets into lanes by the given field and splits
+// each lane by status. With SwimlaneNone a single unnamed lane is returned.
+// The catch all lane is always present so tickets can be dragged into it.
+func SplitIntoSwimlanes(tickets []Ticket, f SwimlaneField) []Swimlane {
+	names := []string{}
+	lanes := map[string]*Swimlane{}
+
+	if f == SwimlanePriority {
+		for _, p := range Priorities {
+			names = append(names, p.String())
+			lanes[p.String()] = &Swimlane{Name: p.String()}
+		}
+	} else {
+		names = append(names, "")
+		lanes[""] = &Swimlane{}
+	}
+
+	for _, t := range tickets {
+		name := f.LaneOf(t)
+		lane, ok := lanes[name]
+		if !ok {
+			lane = &Swimlane{Name: name}
+			lanes[name] = lane
+			names = append(names, name)
+		}
+
+		switch t.Status {
+		case StatusTodo:
+			lane.Todo = append(lane.Todo, t)
+		case StatusInProgress:
+			lane.InProgress = append(lane.InProgress, t)
+		case StatusDone:
+			lane.Done = append(lane.Done, t)
+		}
+	}
+
+	if f == SwimlaneLabel || f == SwimlaneAssignee {
+		// Sort alphabetically with the catch all lane at the bottom.
+		slices.SortFunc(names, func(a, b string) int {
+			if a == "" || b == "" {
+				return strings.Compare(b, a)
+			}
+			return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+		})
+	}
+
+	swimlanes := make([]Swimlane, 0, len(names))
+	for _, n := range names {
+		swimlanes = append(swimlanes, *lanes[n])
+	}
+	return swimlanes
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
@@ -12,15 +13,177 @@ const (
 	StatusDone       Status = "done"
 )
 
+// Statuses lists all statuses in board order.
+var Statuses = []Status{
+	StatusTodo,
+	StatusInProgress,
+	StatusDone,
+}
+
 func (s Status) String() string {
 	return string(s)
 }
 
+type Priority string
+
+const (
+	PriorityNone   Priority = ""
+	PriorityLow    Priority = "low"
+	PriorityMedium Priority = "medium"
+	PriorityHigh   Priority = "high"
+	PriorityUrgent Priority = "urgent"
+)
+
+// Priorities lists the priorities from most to least pressing.
+var Priorities = []Priority{
+	PriorityUrgent,
+	PriorityHigh,
+	PriorityMedium,
+	PriorityLow,
+	PriorityNone,
+}
+
+func (p Priority) String() string {
+	return string(p)
+}
+
 type Ticket struct {
-	Id            string
+	Id string
+	// Number is the sequential number of the ticket on its board.
+	Number int
+	// Key is the human readable key built from the board key prefix and
+	// Number, it is filled in when the ticket is read and never stored.
+	Key           string `json:"-"`
 	Title         string
 	Description   string
 	CreatedAt     time.Time
 	LastUpdatedAt time.Time
 	Status        Status
+	Labels        []string
+	Priority      Priority
+	Assignee      string
+	// Estimate is the size of the ticket in story points, zero means the
+	// ticket has not been estimated.
+	Estimate    int
+	Transitions []Transition
+	// ArchivedAt is set once the ticket is taken off the board, it is kept
+	// for the archive and the stats.
+	ArchivedAt *time.Time
+	// DueAt is the day the ticket is due, at midnight UTC, nil when it has no
+	// due date.
+	DueAt *time.Time `json:",omitempty"`
+	// ExternalRef identifies what the ticket mirrors in another system, such
+	// as a GitHub issue, empty for tickets made on the board.
+	ExternalRef string `json:",omitempty"`
+}
+
+// Archived reports whether the ticket was taken off the board.
+func (t Ticket) Archived() bool {
+	return t.ArchivedAt != nil
+}
+
+// Archive takes the ticket off the board.
+func (t *Ticket) Archive(at time.Time) {
+	t.ArchivedAt = &at
+	t.LastUpdatedAt = at
+}
+
+// Unarchive puts an archived ticket back on the board.
+func (t *Ticket) Unarchive(at time.Time) {
+	t.ArchivedAt = nil
+	t.LastUpdatedAt = at
+}
+
+// Transition records a ticket entering a status. The first transition of a
+// ticket has an empty From status.
+type Transition struct {
+	From Status
+	To   Status
+	At   time.Time
+}
+
+// MoveTo changes the status of the ticket and records the transition. Moving
+// a ticket to the status it already has is a no-op.
+func (t *Ticket) MoveTo(s Status, at time.Time) {
+	if t.Status == s {
+		return
+	}
+
+	t.Transitions = append(t.Transitions, Transition{From: t.Status, To: s, At: at})
+	t.Status = s
+	t.LastUpdatedAt = at
+}
+
+// StartedAt returns when the ticket first entered in progress.
+func (t Ticket) StartedAt() (time.Time, bool) {
+	for _, tr := range t.Transitions {
+		if tr.To == StatusInProgress {
+			return tr.At, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// StatusAt returns the status the ticket had at the given time, or false if
+// the ticket did not exist yet. Tickets created before transitions were
+// recorded are assumed to always have had their current status.
+func (t Ticket) StatusAt(at time.Time) (Status, bool) {
+	if t.CreatedAt.After(at) {
+		return "", false
+	}
+
+	if len(t.Transitions) == 0 {
+		return t.Status, true
+	}
+
+	status := t.Transitions[0].To
+	for _, tr := range t.Transitions {
+		if tr.At.After(at) {
+			break
+		}
+		status = tr.To
+	}
+	return status, true
+}
+
+// CompletedAt returns when a done ticket last entered done.
+func (t Ticket) CompletedAt() (time.Time, bool) {
+	if t.Status != StatusDone {
+		return time.Time{}, false
+	}
+
+	for i := len(t.Transitions) - 1; i >= 0; i-- {
+		if t.Transitions[i].To == StatusDone {
+			return t.Transitions[i].At, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Board is a kanban board. Every user has exactly one board which is
+// identified by the user's id.
+type Board struct {
+	Id        string
+	KeyPrefix string
+	Tickets   []Ticket
+	// Version changes on every write, it is used to detect concurrent
+	// changes.
+	Version          int
+	ArchiveAfterDays int
+}
+
+// ParseLabels splits a comma separated list of labels, trimming whitespace and
+// dropping empty and duplicate entries.
+func ParseLabels(s string) []string {
+	labels := []string{}
+	seen := map[string]bool{}
+	for _, l := range strings.Split(s, ",") {
+		l = strings.TrimSpace(l)
+		if l == "" || seen[l] {
+			continue
+		}
+		seen[l] = true
+		labels = append(labels, l)
+	}
+	return labels
 }
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MaxTitleLength       = 200
+	MaxDescriptionLength = 5000
+	MaxLabels            = 10
+	MaxLabelLength       = 50
+	MaxAssigneeLength    = 100
+	MaxEstimate          = 100
+)
+
+// ValidationErrors maps a field name to a message describing what is wrong
+// with it.
+type ValidationErrors map[string]string
+
+func (v ValidationErrors) Error() string {
+	fields := make([]string, 0, len(v))
+	for f := range v {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, 0, len(v))
+	for _, f := range fields {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", f, v[f]))
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Valid reports whether the status is one of the known board columns.
+func (s Status) Valid() bool {
+	return slices.Contains(Statuses, s)
+}
+
+// Valid reports whether the priority is one of the known priorities.
+func (p Priority) Valid() bool {
+	return slices.Contains(Priorities, p)
+}
+
+// Validate checks the user supplied fields of the ticket. It returns
+// ValidationErrors when any of them are invalid and nil otherwise.
+func (t Ticket) Validate() error {
+	errs := ValidationErrors{}
+
+	switch l := utf8.RuneCountInString(t.Title); {
+	case strings.TrimSpace(t.Title) == "":
+		errs["title"] = "Title is required"
+	case l > MaxTitleLength:
+		errs["title"] = fmt.Sprintf("Title must be at most %d characters", MaxTitleLength)
+	}
+
+	if utf8.RuneCountInString(t.Description) > MaxDescriptionLength {
+		errs["description"] = fmt.Sprintf("Description must be at most %d characters", MaxDescriptionLength)
+	}
+
+	if !t.Status.Valid() {
+		errs["status"] = fmt.Sprintf("Unknown status %q", t.Status)
+	}
+
+	if len(t.Labels) > MaxLabels {
+		errs["labels"] = fmt.Sprintf("At most %d labels are allowed", MaxLabels)
+	}
+	for _, l := range t.Labels {
+		if utf8.RuneCountInString(l) > MaxLabelLength {
+			errs["labels"] = fmt.Sprintf("Labels must be at most %d characters", MaxLabelLength)
+			break
+		}
+	}
+
+	if !t.Priority.Valid() {
+		errs["priority"] = fmt.Sprintf("Unknown priority %q", t.Priority)
+	}
+
+	if utf8.RuneCountInString(t.Assignee) > MaxAssigneeLength {
+		errs["assignee"] = fmt.Sprintf("Assignee must be at most %d characters", MaxAssigneeLength)
+	}
+
+	if t.Estimate < 0 || t.Estimate > MaxEstimate {
+		errs["estimate"] = fmt.Sprintf("Estimate must be between 0 and %d points", MaxEstimate)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
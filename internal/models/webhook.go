@@ -0,0 +1,179 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/netguard"
+)
+
+// MaxWebhookURLLength caps the length of the URL of a webhook.
+const MaxWebhookURLLength = 2000
+
+// Webhook is a URL the changes to the tickets of a board are posted to.
+type Webhook struct {
+	Id      string
+	BoardId string
+	URL     string
+	// Secret signs the deliveries, so the receiver can tell they came from
+	// the board.
+	Secret    string
+	Events    []TicketEvent
+	CreatedAt time.Time
+}
+
+// Wants reports whether the webhook is subscribed to the event.
+func (w Webhook) Wants(e TicketEvent) bool {
+	return slices.Contains(w.Events, e)
+}
+
+// Validate checks the user supplied fields of the webhook. It returns
+// ValidationErrors when any of them are invalid and nil otherwise.
+func (w Webhook) Validate() error {
+	errs := ValidationErrors{}
+
+	u, err := url.Parse(w.URL)
+	switch {
+	case w.URL == "":
+		errs["url"] = "URL is required"
+	case len(w.URL) > MaxWebhookURLLength:
+		errs["url"] = fmt.Sprintf("URL must be at most %d characters", MaxWebhookURLLength)
+	case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+		errs["url"] = "URL must be an absolute http or https URL"
+	case !netguard.IsPublicHost(u.Hostname()):
+		errs["url"] = "URL must point to a public address"
+	}
+
+	if len(w.Events) == 0 {
+		errs["events"] = "At least one event is required"
+	}
+	for _, e := range w.Events {
+		if !e.Valid() {
+			errs["events"] = fmt.Sprintf("Unknown event %q", e)
+			break
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// WebhookDelivery is a single event to be posted to a webhook, along with
+// how its attempts went.
+type WebhookDelivery struct {
+	Id        string
+	WebhookId string
+	Event     TicketEvent
+	// Payload is the JSON body posted to the webhook.
+	Payload  []byte
+	Attempts int
+	// StatusCode is the response status of the last attempt, zero when there
+	// was no response.
+	StatusCode    int
+	Error         string
+	NextAttemptAt *time.Time
+	LastAttemptAt *time.Time
+	DeliveredAt   *time.Time
+	CreatedAt     time.Time
+}
+
+// Delivered reports whether the webhook accepted the delivery.
+func (d WebhookDelivery) Delivered() bool {
+	return d.DeliveredAt != nil
+}
+
+// Failed reports whether the delivery was given up on.
+func (d WebhookDelivery) Failed() bool {
+	return d.DeliveredAt == nil && d.NextAttemptAt == nil
+}
+
+// DueDelivery is a delivery which is due to be attempted, along with where
+// to and how to sign it.
+type DueDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// DeliveryAttempt is the outcome of posting a delivery to its webhook.
+type DeliveryAttempt struct {
+	At         time.Time
+	StatusCode int
+	Error      string
+	Delivered  bool
+	// NextAttemptAt is when to try again, nil when the delivery was delivered
+	// or given up on.
+	NextAttemptAt *time.Time
+}
+
+// WebhookPayload is the JSON body of a delivery.
+type WebhookPayload struct {
+	Event      TicketEvent   `json:"event"`
+	BoardId    string        `json:"board_id"`
+	OccurredAt time.Time     `json:"occurred_at"`
+	Ticket     WebhookTicket `json:"ticket"`
+	FromStatus Status        `json:"from_status,omitempty"`
+}
+
+// WebhookTicket is a ticket as it is sent to webhooks.
+type WebhookTicket struct {
+	Id          string     `json:"id"`
+	Key         string     `json:"key"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Status      Status     `json:"status"`
+	Labels      []string   `json:"labels"`
+	Priority    Priority   `json:"priority"`
+	Assignee    string     `json:"assignee"`
+	Estimate    int        `json:"estimate"`
+	DueDate     string     `json:"due_date,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ArchivedAt  *time.Time `json:"archived_at"`
+}
+
+// NewWebhookPayload builds the payload sent for a change to a ticket of the
+// board.
+func NewWebhookPayload(boardId string, c TicketChange, at time.Time) WebhookPayload {
+	t := c.Ticket
+	labels := t.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+	return WebhookPayload{
+		Event:      c.Event,
+		BoardId:    boardId,
+		OccurredAt: at,
+		FromStatus: c.From,
+		Ticket: WebhookTicket{
+			Id:          t.Id,
+			Key:         t.Key,
+			Title:       t.Title,
+			Description: t.Description,
+			Status:      t.Status,
+			Labels:      labels,
+			Priority:    t.Priority,
+			Assignee:    t.Assignee,
+			Estimate:    t.Estimate,
+			DueDate:     t.DueDate(),
+			CreatedAt:   t.CreatedAt,
+			UpdatedAt:   t.LastUpdatedAt,
+			ArchivedAt:  t.ArchivedAt,
+		},
+	}
+}
+
+// GitHubHook is an endpoint GitHub posts the issue events of a repository to,
+// mirroring the issues as tickets of the board.
+type GitHubHook struct {
+	Id      string
+	BoardId string
+	// Secret is set as the secret of the webhook on GitHub, which signs the
+	// deliveries with it.
+	Secret    string
+	CreatedAt time.Time
+}
@@ -0,0 +1,86 @@
+// Package netguard keeps requests made on behalf of users, such as webhook
+// deliveries, away from loopback, private and other non-public addresses.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// ErrNotPublic is returned when connecting to an address which is not
+// public.
+var ErrNotPublic = errors.New("address is not public")
+
+// reserved lists the ranges which are not reachable on the internet, on top
+// of the private, loopback, link-local and multicast ones netip knows about.
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	// NAT64 and 6to4 embed IPv4 addresses, which may well be private ones.
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("fec0::/10"),
+}
+
+// IsPublic reports whether the address is reachable on the internet, rather
+// than on the host or the network the app runs in.
+func IsPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range reserved {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsPublicHost reports whether the host of a URL may be public. IP addresses
+// are checked with IsPublic, names are only turned down when they can only
+// be internal, what they resolve to is checked by Control when connecting.
+func IsPublicHost(host string) bool {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return IsPublic(addr)
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if !strings.Contains(host, ".") {
+		// Single label names such as "localhost" or "db" resolve through
+		// the local network.
+		return false
+	}
+	for _, suffix := range []string{".localhost", ".local", ".internal", ".home.arpa"} {
+		if strings.HasSuffix(host, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// Control refuses connections to addresses which are not public. It is
+// meant for net.Dialer.Control, which runs after names are resolved, so
+// names resolving to something else by the time of connecting are caught
+// too.
+func Control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", address, err)
+	}
+	if !IsPublic(addrPort.Addr()) {
+		return fmt.Errorf("connecting to %s: %w", address, ErrNotPublic)
+	}
+	return nil
+}
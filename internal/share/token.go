@@ -0,0 +1,48 @@
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidToken = errors.New("invalid share token")
+
+// Signer signs share link ids into tokens so links cannot be guessed, the
+// link itself is still looked up so it can be revoked.
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(secret string) *Signer {
+	return &Signer{key: []byte(secret)}
+}
+
+// Sign returns the token of the share link with the given id.
+func (s *Signer) Sign(id string) string {
+	return id + "." + base64.RawURLEncoding.EncodeToString(s.mac(id))
+}
+
+// Verify checks the signature of the token and returns the id of the share
+// link it was signed for.
+func (s *Signer) Verify(token string) (string, error) {
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.mac(id)) {
+		return "", ErrInvalidToken
+	}
+
+	return id, nil
+}
+
+func (s *Signer) mac(id string) []byte {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte("share:" + id))
+	return m.Sum(nil)
+}
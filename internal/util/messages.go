@@ -1,15 +1,64 @@
 package util
 
+import "time"
+
 type MessageType string
 
 const (
-	MessageTypeError   MessageType = "error"
-	MessageTypeWarning MessageType = "warning"
 	MessageTypeInfo    MessageType = "info"
+	MessageTypeSuccess MessageType = "success"
+	MessageTypeWarning MessageType = "warning"
+	MessageTypeError   MessageType = "error"
 )
 
-// UiMessage holds a typed message string
+// MessageTypes are all message types, from least to most severe.
+var MessageTypes = []MessageType{
+	MessageTypeInfo,
+	MessageTypeSuccess,
+	MessageTypeWarning,
+	MessageTypeError,
+}
+
+// DefaultMessageTimeout is how long messages made by the constructors below
+// are shown before they dismiss themselves.
+const DefaultMessageTimeout = 5 * time.Second
+
+// MessageAction is a link offered along with a message.
+type MessageAction struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// UiMessage is a message shown to the user, either live over SSE or on a
+// server rendered page.
 type UiMessage struct {
-	Type    MessageType `json:"type"`
-	Content string      `json:"content"`
+	Type    MessageType     `json:"type"`
+	Title   string          `json:"title,omitempty"`
+	Content string          `json:"content"`
+	Actions []MessageAction `json:"actions,omitempty"`
+	// Timeout is how long the message is shown before it dismisses itself,
+	// zero keeps it until the user dismisses it.
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+func Info(content string) UiMessage {
+	return UiMessage{Type: MessageTypeInfo, Content: content, Timeout: DefaultMessageTimeout}
+}
+
+func Success(content string) UiMessage {
+	return UiMessage{Type: MessageTypeSuccess, Content: content, Timeout: DefaultMessageTimeout}
+}
+
+func Warning(content string) UiMessage {
+	return UiMessage{Type: MessageTypeWarning, Content: content, Timeout: DefaultMessageTimeout}
+}
+
+func Error(content string) UiMessage {
+	return UiMessage{Type: MessageTypeError, Content: content, Timeout: DefaultMessageTimeout}
+}
+
+// WithAction returns the message with a link added to it.
+func (m UiMessage) WithAction(label, url string) UiMessage {
+	m.Actions = append(m.Actions[:len(m.Actions):len(m.Actions)], MessageAction{Label: label, URL: url})
+	return m
 }
@@ -0,0 +1,400 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/auth"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/components"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/db"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/mail"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/htmx"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/notfound"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/router"
+	"github.com/a-h/templ"
+	"github.com/alexedwards/scs/v2"
+)
+
+const (
+	// maxFormBytes caps the size of the account forms.
+	maxFormBytes = 16 << 10
+	// resetTTL is how long password reset links stay valid, the emails and
+	// pages sending them say an hour.
+	resetTTL = time.Hour
+	// sendResetTimeout bounds creating and emailing a reset link, which
+	// happens after the request was answered.
+	sendResetTimeout = 30 * time.Second
+
+	loginError = "Wrong username, email or password"
+)
+
+type dbClient interface {
+	CheckPasswordReset(ctx context.Context, token string) error
+	CreateAccount(ctx context.Context, account models.Account, claimBoardId string) (models.Account, error)
+	CreateAccountBoard(ctx context.Context, accountId string) (string, error)
+	CreatePasswordReset(ctx context.Context, accountId string, ttl time.Duration) (string, error)
+	GetAccountByLogin(ctx context.Context, login string) (models.Account, error)
+	ResetPassword(ctx context.Context, token, passwordHash string) error
+}
+
+// registerInput holds what was entered in the registration form, the
+// password is never sent back.
+type registerInput struct {
+	Username string
+	Email    string
+}
+
+// NewHandler creates the account handler. baseURL is the configured URL of
+// the app, emailed links are built from it so a forged Host header cannot
+// send them elsewhere.
+func NewHandler(log *slog.Logger, db dbClient, sm *scs.SessionManager, mailer mail.Mailer, baseURL string) http.Handler {
+	h := &handler{
+		log:     log,
+		db:      db,
+		sm:      sm,
+		mailer:  mailer,
+		baseURL: baseURL,
+	}
+
+	h.router = router.New(http.HandlerFunc(h.notFound), http.HandlerFunc(h.methodNotAllowed))
+	h.router.HandleFunc("GET /account/register", h.getRegister)
+	h.router.HandleFunc("POST /account/register", h.postRegister)
+	h.router.HandleFunc("GET /account/login", h.getLogin)
+	h.router.HandleFunc("POST /account/login", h.postLogin)
+	h.router.HandleFunc("POST /account/logout", h.postLogout)
+	h.router.HandleFunc("GET /account/forgot", h.getForgot)
+	h.router.HandleFunc("POST /account/forgot", h.postForgot)
+	h.router.HandleFunc("GET /account/reset/{token}", h.getReset)
+	h.router.HandleFunc("POST /account/reset/{token}", h.postReset)
+
+	return h
+}
+
+type handler struct {
+	log     *slog.Logger
+	db      dbClient
+	sm      *scs.SessionManager
+	mailer  mail.Mailer
+	baseURL string
+	router  *router.Router
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *handler) notFound(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	component := notfound.PageNotFound(r)
+	component.Render(r.Context(), w)
+}
+
+func (h *handler) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	component := components.MethodNotAllowed(r, w.Header().Get("Allow"))
+	component.Render(r.Context(), w)
+}
+
+// serverError responds with the server error page, logging err.
+func (h *handler) serverError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	h.log.Error(message, "error", err.Error())
+	w.WriteHeader(http.StatusInternalServerError)
+	component := components.ServerError(r, message)
+	component.Render(r.Context(), w)
+}
+
+// parseForm reads the posted form, responding with a bad request when it
+// cannot be read.
+func (h *handler) parseForm(w http.ResponseWriter, r *http.Request) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// invalid re-renders a form with what is wrong with it. htmx requests only
+// get the form swapped, everything else the whole page.
+func (h *handler) invalid(w http.ResponseWriter, r *http.Request, formId string, page, form templ.Component) {
+	component := page
+	if htmx.IsRequest(r) {
+		htmx.Retarget(w, "#"+formId, "outerHTML", "#"+formId)
+		component = form
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	component.Render(r.Context(), w)
+}
+
+// logIn puts the account and its board in the session, under a new session
+// token so a token planted before logging in is of no use.
+func (h *handler) logIn(ctx context.Context, account models.Account) error {
+	if err := h.sm.RenewToken(ctx); err != nil {
+		return err
+	}
+	h.sm.Put(ctx, "user", account.BoardId)
+	h.sm.Put(ctx, "account", account.Id)
+	h.sm.Put(ctx, "username", account.Username)
+	h.sm.Put(ctx, "generation", account.SessionGeneration)
+	return nil
+}
+
+func (h *handler) getRegister(w http.ResponseWriter, r *http.Request) {
+	if h.sm.GetString(r.Context(), "account") != "" {
+		http.Redirect(w, r, "/todos", http.StatusSeeOther)
+		return
+	}
+
+	component := registerPage(r, registerInput{}, nil)
+	component.Render(r.Context(), w)
+}
+
+// postRegister creates an account owning the board of the session, or a new
+// one when there is none, and logs into it.
+func (h *handler) postRegister(w http.ResponseWriter, r *http.Request) {
+	if !h.parseForm(w, r) {
+		return
+	}
+
+	form := registerInput{
+		Username: models.NormalizeLogin(r.Form.Get("username")),
+		Email:    models.NormalizeLogin(r.Form.Get("email")),
+	}
+	password := r.Form.Get("password")
+
+	validationErrs := models.ValidationErrors{}
+	if errors.As(models.ValidateRegistration(form.Username, form.Email, password), &validationErrs) {
+		h.invalid(w, r, "register_form", registerPage(r, form, validationErrs), registerForm(form, validationErrs))
+		return
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		h.serverError(w, r, "Unable to register", err)
+		return
+	}
+
+	account, err := h.db.CreateAccount(r.Context(), models.Account{
+		Username:     form.Username,
+		Email:        form.Email,
+		PasswordHash: hash,
+	}, h.sm.GetString(r.Context(), "user"))
+	switch {
+	case errors.Is(err, db.ErrUsernameTaken):
+		validationErrs = models.ValidationErrors{"username": "Username is taken"}
+		h.invalid(w, r, "register_form", registerPage(r, form, validationErrs), registerForm(form, validationErrs))
+		return
+	case errors.Is(err, db.ErrEmailTaken):
+		validationErrs = models.ValidationErrors{"email": "An account with this email already exists"}
+		h.invalid(w, r, "register_form", registerPage(r, form, validationErrs), registerForm(form, validationErrs))
+		return
+	case err != nil:
+		h.serverError(w, r, "Unable to register", err)
+		return
+	}
+
+	if err := h.logIn(r.Context(), account); err != nil {
+		h.serverError(w, r, "Unable to log in", err)
+		return
+	}
+
+	h.log.Info("Registered account", "accountId", account.Id, "boardId", account.BoardId)
+	htmx.Redirect(w, r, "/todos")
+}
+
+func (h *handler) getLogin(w http.ResponseWriter, r *http.Request) {
+	if h.sm.GetString(r.Context(), "account") != "" {
+		http.Redirect(w, r, "/todos", http.StatusSeeOther)
+		return
+	}
+
+	notice := ""
+	if r.URL.Query().Has("reset") {
+		notice = "Your password was reset, log in with the new one."
+	}
+
+	component := loginPage(r, "", notice, nil)
+	component.Render(r.Context(), w)
+}
+
+// postLogin logs into the account, switching the session over to its board.
+// The board of the session before is left to the TTL cleanup.
+func (h *handler) postLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.parseForm(w, r) {
+		return
+	}
+
+	login := strings.TrimSpace(r.Form.Get("login"))
+	password := r.Form.Get("password")
+
+	account, err := h.db.GetAccountByLogin(r.Context(), login)
+	if err != nil && !errors.Is(err, db.ErrAccountNotFound) {
+		h.serverError(w, r, "Unable to log in", err)
+		return
+	}
+	// Unknown accounts are checked against an empty hash, so they take as
+	// long as wrong passwords.
+	if !auth.CheckPassword(account.PasswordHash, password) {
+		validationErrs := models.ValidationErrors{"login": loginError}
+		h.invalid(w, r, "login_form", loginPage(r, login, "", validationErrs), loginForm(login, validationErrs))
+		return
+	}
+
+	if account.BoardId == "" {
+		account.BoardId, err = h.db.CreateAccountBoard(r.Context(), account.Id)
+		if err != nil {
+			h.serverError(w, r, "Unable to create board", err)
+			return
+		}
+	}
+
+	if err := h.logIn(r.Context(), account); err != nil {
+		h.serverError(w, r, "Unable to log in", err)
+		return
+	}
+
+	h.log.Info("Logged in", "accountId", account.Id)
+	htmx.Redirect(w, r, "/todos")
+}
+
+// postLogout ends the session, the board stays with the account.
+func (h *handler) postLogout(w http.ResponseWriter, r *http.Request) {
+	if err := h.sm.Destroy(r.Context()); err != nil {
+		h.serverError(w, r, "Unable to log out", err)
+		return
+	}
+	htmx.Redirect(w, r, "/")
+}
+
+func (h *handler) getForgot(w http.ResponseWriter, r *http.Request) {
+	component := forgotPage(r, "", false)
+	component.Render(r.Context(), w)
+}
+
+// postForgot emails a password reset link to the account. It answers the
+// same whether there is an account or not, so it cannot be used to find out
+// who has one.
+func (h *handler) postForgot(w http.ResponseWriter, r *http.Request) {
+	if !h.parseForm(w, r) {
+		return
+	}
+
+	login := strings.TrimSpace(r.Form.Get("login"))
+
+	account, err := h.db.GetAccountByLogin(r.Context(), login)
+	switch {
+	case errors.Is(err, db.ErrAccountNotFound):
+		h.log.Info("Password reset for unknown account")
+	case err != nil:
+		h.serverError(w, r, "Unable to reset password", err)
+		return
+	default:
+		// Sending takes a while and can fail, neither may show in the
+		// response.
+		go h.sendReset(account)
+	}
+
+	component := forgotPage(r, login, true)
+	if htmx.IsRequest(r) {
+		component = forgotForm(login, true)
+	}
+	component.Render(r.Context(), w)
+}
+
+// sendReset emails the account a link to reset its password with, logging
+// when it fails as nobody is waiting on it.
+func (h *handler) sendReset(account models.Account) {
+	ctx, cancel := context.WithTimeout(context.Background(), sendResetTimeout)
+	defer cancel()
+
+	token, err := h.db.CreatePasswordReset(ctx, account.Id, resetTTL)
+	if err != nil {
+		h.log.Error("Error creating password reset", "accountId", account.Id, "error", err.Error())
+		return
+	}
+
+	err = h.mailer.Send(ctx, mail.Message{
+		To:      account.Email,
+		Subject: "Reset your LambdaBan password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password of %s on LambdaBan.\n\n"+
+				"Follow this link within an hour to choose a new one:\n%s\n\n"+
+				"If it was not you, ignore this email and your password stays as it is.\n",
+			account.Username, h.baseURL+"/account/reset/"+token),
+	})
+	if err != nil {
+		h.log.Error("Error emailing password reset", "accountId", account.Id, "error", err.Error())
+	}
+}
+
+// getReset shows the form choosing a new password, as long as the link is
+// valid.
+func (h *handler) getReset(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+
+	err := h.db.CheckPasswordReset(r.Context(), token)
+	if errors.Is(err, db.ErrPasswordResetNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		component := resetExpiredPage(r)
+		component.Render(r.Context(), w)
+		return
+	}
+	if err != nil {
+		h.serverError(w, r, "Unable to reset password", err)
+		return
+	}
+
+	component := resetPage(r, token, nil)
+	component.Render(r.Context(), w)
+}
+
+// postReset replaces the password of the account the link was sent to, the
+// link can not be used again.
+func (h *handler) postReset(w http.ResponseWriter, r *http.Request) {
+	if !h.parseForm(w, r) {
+		return
+	}
+
+	token := r.PathValue("token")
+	password := r.Form.Get("password")
+
+	validationErrs := models.ValidationErrors{}
+	if errors.As(models.ValidatePassword(password), &validationErrs) {
+		h.invalid(w, r, "reset_form", resetPage(r, token, validationErrs), resetForm(token, validationErrs))
+		return
+	}
+	if password != r.Form.Get("confirm_password") {
+		validationErrs = models.ValidationErrors{"confirm_password": "Passwords do not match"}
+		h.invalid(w, r, "reset_form", resetPage(r, token, validationErrs), resetForm(token, validationErrs))
+		return
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		h.serverError(w, r, "Unable to reset password", err)
+		return
+	}
+
+	err = h.db.ResetPassword(r.Context(), token, hash)
+	if errors.Is(err, db.ErrPasswordResetNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		component := resetExpiredPage(r)
+		component.Render(r.Context(), w)
+		return
+	}
+	if err != nil {
+		h.serverError(w, r, "Unable to reset password", err)
+		return
+	}
+
+	h.log.Info("Reset password")
+	// Every session of the account was just revoked, this one included when
+	// it was logged in, it logs in again with the new password.
+	htmx.Redirect(w, r, "/account/login?reset")
+}
@@ -0,0 +1,65 @@
+package htmx
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// IsRequest reports whether the request was issued by htmx.
+func IsRequest(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "true"
+}
+
+// IsBoosted reports whether the request came from a boosted link or form,
+// which swaps the whole body and so needs the whole page.
+func IsBoosted(r *http.Request) bool {
+	return r.Header.Get("HX-Boosted") == "true"
+}
+
+// IsHistoryRestore reports whether htmx is fetching a page missing from its
+// history cache, which also needs the whole page.
+func IsHistoryRestore(r *http.Request) bool {
+	return r.Header.Get("HX-History-Restore-Request") == "true"
+}
+
+// WantsFragment reports whether only the swapped part of a page needs to be
+// rendered. Responses varying on it are marked as such for caches.
+func WantsFragment(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Add("Vary", "HX-Request")
+	return IsRequest(r) && !IsBoosted(r) && !IsHistoryRestore(r)
+}
+
+// CurrentURL returns the URL of the page the request was made from, or nil
+// when htmx did not send it.
+func CurrentURL(r *http.Request) *url.URL {
+	u, err := url.Parse(r.Header.Get("HX-Current-URL"))
+	if err != nil || r.Header.Get("HX-Current-URL") == "" {
+		return nil
+	}
+	return u
+}
+
+// Retarget tells htmx to swap the response into target using the given swap
+// strategy, selecting only the selector part of the response.
+func Retarget(w http.ResponseWriter, target, swap, selector string) {
+	w.Header().Set("HX-Retarget", target)
+	w.Header().Set("HX-Reswap", swap)
+	w.Header().Set("HX-Reselect", selector)
+}
+
+// Trigger makes htmx trigger the event on the element which made the request
+// once the response arrives, whether it succeeded or not.
+func Trigger(w http.ResponseWriter, event string) {
+	w.Header().Set("HX-Trigger", event)
+}
+
+// Redirect sends the client to url, htmx requests are told to load it as a
+// full page rather than swapping the response.
+func Redirect(w http.ResponseWriter, r *http.Request, url string) {
+	if IsRequest(r) {
+		w.Header().Set("HX-Redirect", url)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
@@ -2,43 +2,131 @@ package notifications
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/JamesTiberiusKirk/lambdaban/internal/components"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/events"
 	"github.com/JamesTiberiusKirk/lambdaban/internal/metrics"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/util"
 	"github.com/alexedwards/scs/v2"
 )
 
-// Notification is the message sent to the client
-type Notification struct {
-	Type    string
-	Content string
+type dbClient interface {
+	AddNotifications(ctx context.Context, notifications []models.Notification) error
+	ClearNotifications(ctx context.Context, userId string) error
+	CountUnreadNotifications(ctx context.Context, userId string) (int, error)
+	GetMutedMessageTypes(ctx context.Context, userId string) ([]util.MessageType, error)
+	GetNotifications(ctx context.Context, userId string, limit int) ([]models.Notification, error)
+	MarkAllNotificationsRead(ctx context.Context, userId string) error
+	MarkNotificationRead(ctx context.Context, userId, id string) error
+	SetMutedMessageTypes(ctx context.Context, userId string, muted []util.MessageType) error
 }
 
-// SSEConnection holds the notification channel and a done signal
+// BoardRenderer renders the htmx out-of-band swaps which bring an open board
+// up to date after it changed.
+type BoardRenderer interface {
+	RenderBoardUpdate(ctx context.Context, w io.Writer, boardId string) error
+}
+
+// HeartbeatInterval is how often SSE streams send a heartbeat comment, which
+// keeps idle streams from being closed by proxies.
+const HeartbeatInterval = 15 * time.Second
+
+const (
+	// maxConnectionsPerUser caps the open SSE connections of a single user,
+	// one per tab or device.
+	maxConnectionsPerUser = 8
+	// connectionBuffer is how many notifications a connection can fall behind
+	// by before further ones are dropped.
+	connectionBuffer = 8
+	// replayBufferSize is how many of the latest notifications of a user are
+	// kept to be sent again to a client reconnecting with Last-Event-ID.
+	replayBufferSize = 32
+	// replayTTL is how long the notifications of a user without any open
+	// connection are kept for them to reconnect.
+	replayTTL = 2 * time.Minute
+	// retryMillis is how long clients wait before reconnecting.
+	retryMillis = 3000
+	// storeTimeout bounds keeping a batch of notifications in the inbox and
+	// sending them, which happens outside of any request context.
+	storeTimeout = 5 * time.Second
+	// storeQueueSize is how many notifications can wait to be kept before
+	// further ones are dropped.
+	storeQueueSize = 1024
+	// storeBatchSize caps the notifications kept at once.
+	storeBatchSize = 100
+)
+
+// sentNotification is a message along with the id of its SSE event.
+type sentNotification struct {
+	Id      uint64
+	Message util.UiMessage
+}
+
+// SSEConnection holds the notification channel of a single open stream
 type SSEConnection struct {
-	NotifyCh chan Notification
-	Done     chan struct{}
+	NotifyCh chan sentNotification
+}
+
+// userStream holds the open connections of a user and the notifications they
+// were sent lately, which outlive the connections by replayTTL.
+type userStream struct {
+	conns  map[*SSEConnection]struct{}
+	replay []sentNotification
+	expiry *time.Timer
 }
 
 // NotificationsHandler manages all SSE connections
 type NotificationsHandler struct {
 	log     *slog.Logger
 	m       *metrics.Metrics
-	mu      sync.RWMutex
-	clients map[string]*SSEConnection // userID -> connection
+	mu      sync.Mutex
+	clients map[string]*userStream // userID -> stream
+	lastId  uint64
+	db      dbClient
 	sm      *scs.SessionManager
+	events  *events.Broker
+	board   BoardRenderer
+	pending chan models.Notification
 }
 
-// NewNotificationsHandler creates a new handler
-func NewNotificationsHandler(log *slog.Logger, m *metrics.Metrics, sm *scs.SessionManager) *NotificationsHandler {
+// NewNotificationsHandler creates a new handler. Notifications are kept in
+// the inbox of the user in db as well as sent live. Changes to the board of the
+// user published on the broker are rendered by board and sent along with the
+// notifications, so every open view of the board stays in sync.
+func NewNotificationsHandler(
+	log *slog.Logger,
+	m *metrics.Metrics,
+	sm *scs.SessionManager,
+	db dbClient,
+	broker *events.Broker,
+	board BoardRenderer,
+) *NotificationsHandler {
 	return &NotificationsHandler{
 		log:     log,
 		m:       m,
-		clients: make(map[string]*SSEConnection),
+		clients: make(map[string]*userStream),
+		// The replay buffer is in memory, notifications sent before a restart
+		// are lost with it. Starting from the clock keeps ids increasing
+		// across restarts, so the Last-Event-ID of a client reconnecting
+		// after one is older than anything sent since and does not hold back
+		// new notifications.
+		lastId:  uint64(time.Now().UnixNano()),
+		db:      db,
 		sm:      sm,
+		events:  broker,
+		board:   board,
+		pending: make(chan models.Notification, storeQueueSize),
 	}
 }
 
@@ -57,75 +145,322 @@ func (h *NotificationsHandler) ServeSSE(w http.ResponseWriter, r *http.Request)
 
 	h.log.Info("SSE", "userID", userID)
 
-	// Ensure only one connection per user
-	h.mu.Lock()
-	if oldConn, ok := h.clients[userID]; ok {
-		close(oldConn.Done)
-		delete(h.clients, userID)
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		h.log.Error("Streaming unsupported")
+		return
 	}
-	conn := &SSEConnection{
-		NotifyCh: make(chan Notification, 8),
-		Done:     make(chan struct{}),
+
+	lastEventId, reconnected := parseLastEventId(r)
+	conn, missed, ok := h.addConnection(userID, lastEventId)
+	if !ok {
+		http.Error(w, "Too many connections", http.StatusTooManyRequests)
+		h.log.Warn("SSE connection limit reached", "userID", userID)
+		return
 	}
-	h.clients[userID] = conn
-	h.mu.Unlock()
+	defer h.removeConnection(userID, conn)
 
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	h.m.SSENotificationConnections.Add(1)
 
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
-		h.log.Error("Streaming unsupported")
-		return
-	}
-
-	// Cleanup on disconnect
-	defer func() {
-		h.mu.Lock()
-		delete(h.clients, userID)
-		h.mu.Unlock()
-		close(conn.NotifyCh)
-		h.m.SSENotificationConnections.Sub(1)
-		w.WriteHeader(http.StatusOK)
-	}()
+	// The board of the user shares their id.
+	boardEvents, cancel := h.events.Subscribe(userID)
+	defer cancel()
 
 	ctx := r.Context()
 
+	WriteRetry(w)
+	for _, n := range missed {
+		h.writeNotification(ctx, w, n)
+	}
+	if reconnected {
+		// Board changes are not replayed, bring the board up to date instead.
+		h.writeBoardUpdate(ctx, w, userID)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-conn.Done:
-			return
 		case n := <-conn.NotifyCh:
-			var buf bytes.Buffer
-			err := notification(n).Render(r.Context(), &buf)
-			if err != nil {
-				fmt.Fprintf(w, "event: error\ndata: %s\n\n", "error creating notification")
-				continue
+			h.writeNotification(ctx, w, n)
+			flusher.Flush()
+		case <-boardEvents:
+			h.writeBoardUpdate(ctx, w, userID)
+			flusher.Flush()
+		case <-heartbeat.C:
+			WriteHeartbeat(w)
+			flusher.Flush()
+		}
+	}
+}
+
+// parseLastEventId returns the id of the last event a reconnecting client
+// got, and whether it is reconnecting at all.
+func parseLastEventId(r *http.Request) (uint64, bool) {
+	header := r.Header.Get("Last-Event-ID")
+	if header == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(header, 10, 64)
+	if err != nil {
+		return 0, true
+	}
+	return id, true
+}
+
+func (h *NotificationsHandler) writeNotification(ctx context.Context, w io.Writer, n sentNotification) {
+	var buf bytes.Buffer
+	err := components.Message(n.Message).Render(ctx, &buf)
+	if err != nil {
+		fmt.Fprintf(w, "event: error\ndata: %s\n\n", "error creating notification")
+		return
+	}
+
+	writeEvent(w, strconv.FormatUint(n.Id, 10), "notification", buf.String())
+}
+
+func (h *NotificationsHandler) writeBoardUpdate(ctx context.Context, w io.Writer, userID string) {
+	var buf bytes.Buffer
+	err := h.board.RenderBoardUpdate(ctx, &buf, userID)
+	if err != nil {
+		h.log.Error("Error rendering board update", "userID", userID, "error", err.Error())
+		return
+	}
+
+	writeEvent(w, "", "board", buf.String())
+}
+
+// addConnection registers a new connection of the user, as long as they are
+// below maxConnectionsPerUser. It returns the notifications sent after
+// lastEventId, which the connection missed.
+func (h *NotificationsHandler) addConnection(userID string, lastEventId uint64) (*SSEConnection, []sentNotification, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stream := h.clients[userID]
+	if stream == nil {
+		stream = &userStream{conns: make(map[*SSEConnection]struct{})}
+		h.clients[userID] = stream
+	}
+	if len(stream.conns) >= maxConnectionsPerUser {
+		return nil, nil, false
+	}
+	if stream.expiry != nil {
+		stream.expiry.Stop()
+		stream.expiry = nil
+	}
+
+	missed := []sentNotification{}
+	if lastEventId != 0 {
+		for _, n := range stream.replay {
+			if n.Id > lastEventId {
+				missed = append(missed, n)
 			}
+		}
+	}
 
-			fmt.Fprintf(w, "event: notification\ndata: %s\n\n", buf.String())
-			flusher.Flush()
+	conn := &SSEConnection{
+		NotifyCh: make(chan sentNotification, connectionBuffer),
+	}
+	stream.conns[conn] = struct{}{}
+
+	h.m.SSENotificationConnections.Inc()
+	h.moveUserBucket(len(stream.conns)-1, len(stream.conns))
+	return conn, missed, true
+}
+
+// removeConnection unregisters a connection of the user, leaving their other
+// connections open. Once the last one is gone the notifications of the user
+// are kept for replayTTL in case they reconnect.
+func (h *NotificationsHandler) removeConnection(userID string, conn *SSEConnection) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stream := h.clients[userID]
+	delete(stream.conns, conn)
+	h.m.SSENotificationConnections.Dec()
+	h.moveUserBucket(len(stream.conns)+1, len(stream.conns))
+	if len(stream.conns) > 0 {
+		return
+	}
+
+	stream.expiry = time.AfterFunc(replayTTL, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.clients[userID] == stream && len(stream.conns) == 0 {
+			delete(h.clients, userID)
 		}
+	})
+}
+
+// moveUserBucket moves a user from the bucket of users with from connections
+// to the one with to connections. Users are counted by their number of
+// connections rather than labelled by id, which would grow a series per user.
+func (h *NotificationsHandler) moveUserBucket(from, to int) {
+	if from > 0 {
+		h.m.SSEUsersByConnections.WithLabelValues(strconv.Itoa(from)).Dec()
+	}
+	if to > 0 {
+		h.m.SSEUsersByConnections.WithLabelValues(strconv.Itoa(to)).Inc()
 	}
 }
 
-// Notify sends a notification to the user's SSE connection
-func (h *NotificationsHandler) Notify(userID string, n Notification) {
-	h.mu.RLock()
-	conn, ok := h.clients[userID]
-	h.mu.RUnlock()
-	if !ok {
-		return // No active connection
+// WriteRetry tells the client of an SSE stream how long to wait before
+// reconnecting once the stream is closed.
+func WriteRetry(w io.Writer) {
+	fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+}
+
+// WriteHeartbeat writes a comment to an SSE stream, meant to be sent every
+// HeartbeatInterval.
+func WriteHeartbeat(w io.Writer) {
+	fmt.Fprint(w, ": heartbeat\n\n")
+}
+
+// writeEvent writes a single SSE event, every line of data goes in a data
+// field of its own so multi line HTML arrives in one piece. Events without an
+// id leave the last event id of the client as it is.
+func writeEvent(w io.Writer, id, name, data string) {
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\n", name)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+// Notify queues a message for the inbox of the user and every open SSE
+// connection of theirs, so the request notifying does not wait on the
+// database. The message is dropped when the queue is full.
+func (h *NotificationsHandler) Notify(userID string, m util.UiMessage) {
+	if userID == "" {
+		return
 	}
+
 	select {
-	case conn.NotifyCh <- n:
+	case h.pending <- models.Notification{UserId: userID, Message: m, CreatedAt: time.Now()}:
 	default:
-		// Channel full, drop or handle overflow
+		h.log.Error("Notification queue full, dropping notification", "userID", userID, "title", m.Title)
+	}
+}
+
+// Start keeps the queued notifications in the inbox, in batches of whatever
+// queued up since the last one, and then sends them to their users. It stops
+// once the context is done, keeping the notifications left in the queue.
+func (h *NotificationsHandler) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case n := <-h.pending:
+				h.store(h.batch(n))
+			case <-ctx.Done():
+				if len(h.pending) > 0 {
+					h.store(h.batch())
+				}
+				h.log.Info("Notification writer stopped")
+				return
+			}
+		}
+	}()
+}
+
+// batch adds whatever else is queued to the notifications, up to
+// storeBatchSize, without waiting for more.
+func (h *NotificationsHandler) batch(notifications ...models.Notification) []models.Notification {
+	for len(notifications) < storeBatchSize {
+		select {
+		case n := <-h.pending:
+			notifications = append(notifications, n)
+		default:
+			return notifications
+		}
+	}
+	return notifications
+}
+
+// store keeps the notifications in the inbox of their users and sends them,
+// they are sent only once kept so the inbox is up to date by the time
+// clients ask for it.
+func (h *NotificationsHandler) store(notifications []models.Notification) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	err := h.db.AddNotifications(ctx, notifications)
+	if err != nil && len(notifications) > 1 {
+		// A single notification, such as one for a board deleted since,
+		// fails the whole batch, keep the others one by one.
+		for i := range notifications {
+			err := h.db.AddNotifications(ctx, notifications[i:i+1])
+			if err != nil {
+				h.log.Error("Error keeping notification", "userID", notifications[i].UserId, "error", err.Error())
+			}
+		}
+	} else if err != nil {
+		h.log.Error("Error keeping notification", "userID", notifications[0].UserId, "error", err.Error())
+	}
+
+	for _, n := range notifications {
+		h.send(ctx, n.UserId, n.Message)
+	}
+}
+
+// send sends a message to every open SSE connection of the user, unless they
+// muted its type, keeping it for replaying to connections which missed it. A
+// connection which fell too far behind misses it, without holding up the
+// others. Users who have not connected lately only find it in their inbox.
+func (h *NotificationsHandler) send(ctx context.Context, userID string, m util.UiMessage) {
+	if h.muted(ctx, userID, m.Type) {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stream := h.clients[userID]
+	if stream == nil {
+		return // No active connection
+	}
+
+	h.lastId++
+	sent := sentNotification{Id: h.lastId, Message: m}
+	stream.replay = append(stream.replay, sent)
+	if len(stream.replay) > replayBufferSize {
+		stream.replay = stream.replay[len(stream.replay)-replayBufferSize:]
+	}
+
+	for conn := range stream.conns {
+		select {
+		case conn.NotifyCh <- sent:
+		default:
+			h.m.SSEDroppedNotificationsTotal.Inc()
+		}
+	}
+}
+
+// muted reports whether the user muted toasts of the message type. Only users
+// with a stream are looked up, nobody else would see the toast anyway.
+func (h *NotificationsHandler) muted(ctx context.Context, userID string, t util.MessageType) bool {
+	h.mu.Lock()
+	_, connected := h.clients[userID]
+	h.mu.Unlock()
+	if !connected {
+		return false
+	}
+
+	muted, err := h.db.GetMutedMessageTypes(ctx, userID)
+	if err != nil {
+		h.log.Error("Error fetching muted message types", "userID", userID, "error", err.Error())
+		return false
 	}
+	return slices.Contains(muted, t)
 }
@@ -0,0 +1,185 @@
+package notifications
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/components"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/db"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/util"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/htmx"
+)
+
+// inboxSize is how many of the latest notifications the inbox shows.
+const inboxSize = 50
+
+var (
+	errNoSession  = errors.New("no user in session")
+	errBadRequest = errors.New("bad request")
+)
+
+// ServeBell renders the bell showing how many notifications are unread, the
+// Layout loads it lazily.
+func (h *NotificationsHandler) ServeBell(w http.ResponseWriter, r *http.Request) {
+	userID := h.sm.GetString(r.Context(), "user")
+	if userID == "" {
+		bell(0).Render(r.Context(), w)
+		return
+	}
+
+	unread, err := h.db.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		h.fail(w, r, "Unable to fetch notifications", err)
+		return
+	}
+
+	bell(unread).Render(r.Context(), w)
+}
+
+// ServeInbox renders the latest notifications of the user.
+func (h *NotificationsHandler) ServeInbox(w http.ResponseWriter, r *http.Request) {
+	userID := h.sm.GetString(r.Context(), "user")
+	if userID == "" {
+		// The board creates the user, there is nothing in the inbox before that.
+		http.Redirect(w, r, "/todos", http.StatusSeeOther)
+		return
+	}
+
+	notifications, err := h.db.GetNotifications(r.Context(), userID, inboxSize)
+	if err != nil {
+		h.fail(w, r, "Unable to fetch notifications", err)
+		return
+	}
+
+	muted, err := h.db.GetMutedMessageTypes(r.Context(), userID)
+	if err != nil {
+		h.fail(w, r, "Unable to fetch notification preferences", err)
+		return
+	}
+
+	component := inboxPage(r, notifications, muted)
+	if htmx.WantsFragment(w, r) {
+		component = inboxPanel(notifications)
+	}
+	component.Render(r.Context(), w)
+}
+
+// ServeMarkRead marks a single notification as read.
+func (h *NotificationsHandler) ServeMarkRead(w http.ResponseWriter, r *http.Request) {
+	userID := h.sm.GetString(r.Context(), "user")
+	if userID == "" {
+		h.fail(w, r, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	err := h.db.MarkNotificationRead(r.Context(), userID, r.PathValue("id"))
+	if err != nil {
+		h.fail(w, r, "Unable to mark notification as read", err)
+		return
+	}
+
+	htmx.Trigger(w, components.InboxChangedEvent)
+	h.ServeInbox(w, r)
+}
+
+// ServeMarkAllRead marks every notification as read.
+func (h *NotificationsHandler) ServeMarkAllRead(w http.ResponseWriter, r *http.Request) {
+	userID := h.sm.GetString(r.Context(), "user")
+	if userID == "" {
+		h.fail(w, r, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	err := h.db.MarkAllNotificationsRead(r.Context(), userID)
+	if err != nil {
+		h.fail(w, r, "Unable to mark notifications as read", err)
+		return
+	}
+
+	htmx.Trigger(w, components.InboxChangedEvent)
+	h.ServeInbox(w, r)
+}
+
+// ServeClear empties the inbox.
+func (h *NotificationsHandler) ServeClear(w http.ResponseWriter, r *http.Request) {
+	userID := h.sm.GetString(r.Context(), "user")
+	if userID == "" {
+		h.fail(w, r, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	err := h.db.ClearNotifications(r.Context(), userID)
+	if err != nil {
+		h.fail(w, r, "Unable to clear notifications", err)
+		return
+	}
+
+	htmx.Trigger(w, components.InboxChangedEvent)
+	h.ServeInbox(w, r)
+}
+
+// ServePreferences mutes toasts of every message type not picked to be shown.
+func (h *NotificationsHandler) ServePreferences(w http.ResponseWriter, r *http.Request) {
+	userID := h.sm.GetString(r.Context(), "user")
+	if userID == "" {
+		h.fail(w, r, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.fail(w, r, "Error parsing form", errBadRequest)
+		return
+	}
+
+	muted := []util.MessageType{}
+	for _, t := range util.MessageTypes {
+		if !slices.Contains(r.Form["show"], string(t)) {
+			muted = append(muted, t)
+		}
+	}
+
+	err := h.db.SetMutedMessageTypes(r.Context(), userID, muted)
+	if err != nil {
+		h.fail(w, r, "Unable to save notification preferences", err)
+		return
+	}
+
+	if !htmx.IsRequest(r) {
+		http.Redirect(w, r, "/notifications/inbox", http.StatusSeeOther)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// fail responds to a failed inbox request, htmx requests get an ActionError
+// swapped into the Layout.
+func (h *NotificationsHandler) fail(w http.ResponseWriter, r *http.Request, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errBadRequest):
+		status = http.StatusBadRequest
+	case errors.Is(err, errNoSession):
+		status = http.StatusUnauthorized
+	case errors.Is(err, db.ErrNotificationNotFound):
+		status = http.StatusNotFound
+	}
+
+	if status >= http.StatusInternalServerError {
+		h.log.Error(message, "status", status, "error", err.Error())
+	} else {
+		h.log.Warn(message, "status", status, "error", err.Error())
+	}
+
+	if !htmx.IsRequest(r) {
+		w.WriteHeader(status)
+		component := components.ServerError(r, message)
+		component.Render(r.Context(), w)
+		return
+	}
+
+	htmx.Retarget(w, "#action_errors", "innerHTML", "#action_error")
+	w.WriteHeader(status)
+	component := components.ActionError(message)
+	component.Render(r.Context(), w)
+}
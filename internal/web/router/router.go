@@ -0,0 +1,79 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+)
+
+// methods are the methods probed when working out which methods a path
+// supports.
+var methods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// Router wraps http.ServeMux so requests which match no route are served by
+// NotFound and requests which match a path but not its method are served by
+// MethodNotAllowed, instead of the plain text responses of the mux.
+type Router struct {
+	mux              *http.ServeMux
+	notFound         http.Handler
+	methodNotAllowed http.Handler
+}
+
+// New creates a router. methodNotAllowed is called with the Allow header
+// already set.
+func New(notFound, methodNotAllowed http.Handler) *Router {
+	return &Router{
+		mux:              http.NewServeMux(),
+		notFound:         notFound,
+		methodNotAllowed: methodNotAllowed,
+	}
+}
+
+// Handle registers the handler for the given ServeMux pattern.
+func (rt *Router) Handle(pattern string, handler http.Handler) {
+	rt.mux.Handle(pattern, handler)
+}
+
+// HandleFunc registers the handler function for the given ServeMux pattern.
+func (rt *Router) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.mux.HandleFunc(pattern, handler)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rt.mux.Handler(r); pattern != "" {
+		rt.mux.ServeHTTP(w, r)
+		return
+	}
+
+	allowed := rt.allowedMethods(r)
+	if len(allowed) == 0 {
+		rt.notFound.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	rt.methodNotAllowed.ServeHTTP(w, r)
+}
+
+// allowedMethods returns the methods for which a route matches the path of
+// the request.
+func (rt *Router) allowedMethods(r *http.Request) []string {
+	allowed := []string{}
+	for _, m := range methods {
+		if m == r.Method {
+			continue
+		}
+
+		probe := r.Clone(r.Context())
+		probe.Method = m
+		if _, pattern := rt.mux.Handler(probe); pattern != "" {
+			allowed = append(allowed, m)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,289 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/analytics"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/components"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/htmx"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/notfound"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/router"
+	"github.com/alexedwards/scs/v2"
+)
+
+type dbClient interface {
+	GetBoard(ctx context.Context, id string) (models.Board, error)
+}
+
+// windows are the selectable reporting windows in days.
+var windows = []int{7, 14, 30, 90, 365}
+
+const defaultWindow = 30
+
+func NewHandler(log *slog.Logger, db dbClient, sm *scs.SessionManager) http.Handler {
+	h := &handler{
+		log: log,
+		db:  db,
+		sm:  sm,
+		now: time.Now,
+	}
+
+	h.router = router.New(http.HandlerFunc(h.notFound), http.HandlerFunc(h.methodNotAllowed))
+	h.router.HandleFunc("GET /todos/stats", h.get)
+	h.router.HandleFunc("GET /todos/stats/burn", h.getBurn)
+
+	return h
+}
+
+type handler struct {
+	log    *slog.Logger
+	db     dbClient
+	sm     *scs.SessionManager
+	now    func() time.Time
+	router *router.Router
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *handler) notFound(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	component := notfound.PageNotFound(r)
+	component.Render(r.Context(), w)
+}
+
+func (h *handler) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	component := components.MethodNotAllowed(r, w.Header().Get("Allow"))
+	component.Render(r.Context(), w)
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		// The board creates the user, there is nothing to report before that.
+		http.Redirect(w, r, "/todos", http.StatusSeeOther)
+		return
+	}
+
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || !slices.Contains(windows, days) {
+		days = defaultWindow
+	}
+
+	board, err := h.db.GetBoard(r.Context(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The board was cleaned up after being inactive, the board page
+		// starts a new one.
+		http.Redirect(w, r, "/todos", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		h.log.Error("Error fetching board", "userId", userId, "error", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		component := components.ServerError(r, "Unable to fetch tickets")
+		component.Render(r.Context(), w)
+		return
+	}
+
+	to := h.now()
+	from := to.AddDate(0, 0, -days)
+	report := analytics.ComputeFlow(board.Tickets, from, to)
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(newFlowResponse(days, report))
+		if err != nil {
+			h.log.Error("Error encoding stats", "error", err.Error())
+		}
+		return
+	}
+
+	component := page(r, days, report)
+	if htmx.WantsFragment(w, r) {
+		component = statsPanel(days, report)
+	}
+	component.Render(r.Context(), w)
+}
+
+// defaultBurnDays is how far back burn charts go when no range is given.
+const defaultBurnDays = 14
+
+const dateFormat = "2006-01-02"
+
+func (h *handler) getBurn(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		http.Redirect(w, r, "/todos", http.StatusSeeOther)
+		return
+	}
+
+	now := h.now()
+	to, err := time.ParseInLocation(dateFormat, r.URL.Query().Get("to"), now.Location())
+	if err != nil {
+		to = now
+	}
+	from, err := time.ParseInLocation(dateFormat, r.URL.Query().Get("from"), now.Location())
+	if err != nil || from.After(to) {
+		from = to.AddDate(0, 0, -defaultBurnDays)
+	}
+	if to.Sub(from) > analytics.MaxBurnDays*24*time.Hour {
+		from = to.AddDate(0, 0, -analytics.MaxBurnDays+1)
+	}
+	unit := analytics.ParseBurnUnit(r.URL.Query().Get("unit"))
+
+	board, err := h.db.GetBoard(r.Context(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Redirect(w, r, "/todos", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		h.log.Error("Error fetching board", "userId", userId, "error", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		component := components.ServerError(r, "Unable to fetch tickets")
+		component.Render(r.Context(), w)
+		return
+	}
+
+	points := analytics.ComputeBurn(board.Tickets, from, to, now, unit)
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(newBurnResponse(from, to, unit, points))
+		if err != nil {
+			h.log.Error("Error encoding burn chart", "error", err.Error())
+		}
+		return
+	}
+
+	component := burnPage(r, from, to, unit, points)
+	if htmx.WantsFragment(w, r) {
+		component = burnPanel(from, to, unit, points)
+	}
+	component.Render(r.Context(), w)
+}
+
+func wantsJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "json" ||
+		strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+type flowResponse struct {
+	From      time.Time            `json:"from"`
+	To        time.Time            `json:"to"`
+	Days      int                  `json:"days"`
+	Completed int                  `json:"completed"`
+	LeadTime  percentilesResponse  `json:"lead_time"`
+	CycleTime percentilesResponse  `json:"cycle_time"`
+	Tickets   []ticketFlowResponse `json:"tickets"`
+}
+
+type percentilesResponse struct {
+	P50Hours float64 `json:"p50_hours"`
+	P85Hours float64 `json:"p85_hours"`
+	P95Hours float64 `json:"p95_hours"`
+}
+
+type ticketFlowResponse struct {
+	Id             string    `json:"id"`
+	Key            string    `json:"key"`
+	Title          string    `json:"title"`
+	CompletedAt    time.Time `json:"completed_at"`
+	LeadTimeHours  float64   `json:"lead_time_hours"`
+	CycleTimeHours *float64  `json:"cycle_time_hours"`
+}
+
+func newFlowResponse(days int, report analytics.FlowReport) flowResponse {
+	res := flowResponse{
+		From:      report.From,
+		To:        report.To,
+		Days:      days,
+		Completed: len(report.Tickets),
+		LeadTime:  newPercentilesResponse(report.LeadTime),
+		CycleTime: newPercentilesResponse(report.CycleTime),
+		Tickets:   make([]ticketFlowResponse, 0, len(report.Tickets)),
+	}
+
+	for _, f := range report.Tickets {
+		t := ticketFlowResponse{
+			Id:            f.Ticket.Id,
+			Key:           f.Ticket.Key,
+			Title:         f.Ticket.Title,
+			CompletedAt:   f.CompletedAt,
+			LeadTimeHours: f.LeadTime.Hours(),
+		}
+		if f.HasCycleTime {
+			hours := f.CycleTime.Hours()
+			t.CycleTimeHours = &hours
+		}
+		res.Tickets = append(res.Tickets, t)
+	}
+
+	return res
+}
+
+func newPercentilesResponse(p analytics.Percentiles) percentilesResponse {
+	return percentilesResponse{
+		P50Hours: p.P50.Hours(),
+		P85Hours: p.P85.Hours(),
+		P95Hours: p.P95.Hours(),
+	}
+}
+
+type burnResponse struct {
+	From   string              `json:"from"`
+	To     string              `json:"to"`
+	Unit   analytics.BurnUnit  `json:"unit"`
+	Points []burnPointResponse `json:"points"`
+}
+
+type burnPointResponse struct {
+	Day       string  `json:"day"`
+	Scope     float64 `json:"scope"`
+	Done      float64 `json:"done"`
+	Remaining float64 `json:"remaining"`
+}
+
+func newBurnResponse(from, to time.Time, unit analytics.BurnUnit, points []analytics.BurnPoint) burnResponse {
+	res := burnResponse{
+		From:   from.Format(dateFormat),
+		To:     to.Format(dateFormat),
+		Unit:   unit,
+		Points: make([]burnPointResponse, 0, len(points)),
+	}
+
+	for _, p := range points {
+		res.Points = append(res.Points, burnPointResponse{
+			Day:       p.Day.Format(dateFormat),
+			Scope:     p.Scope,
+			Done:      p.Done,
+			Remaining: p.Remaining(),
+		})
+	}
+
+	return res
+}
+
+// formatDuration prints a duration in the largest sensible unit.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%.1fd", d.Hours()/24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%.1fh", d.Hours())
+	default:
+		return fmt.Sprintf("%.0fm", d.Minutes())
+	}
+}
@@ -0,0 +1,133 @@
+package todos
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/util"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/htmx"
+)
+
+// archivePageSize is how many archived tickets are shown per page.
+const archivePageSize = 20
+
+// archiveView holds everything needed to render a page of the archive.
+type archiveView struct {
+	Query   string
+	Page    int
+	Pages   int
+	Total   int
+	Tickets []models.Ticket
+}
+
+// archive takes a ticket off the board.
+func (h *handler) archive(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	ticket, err := h.db.GetTicketByUser(r.Context(), userId, r.PathValue("id"))
+	if err != nil {
+		h.fail(w, r, userId, "Error fetching ticket", err, "ref", r.PathValue("id"))
+		return
+	}
+
+	if !ticket.Archived() {
+		ticket.Archive(time.Now())
+		err = h.db.UpdateTicket(r.Context(), userId, ticket)
+		if err != nil {
+			h.fail(w, r, userId, "Error archiving ticket", err, "ticketId", ticket.Id)
+			return
+		}
+	}
+
+	h.nh.Notify(userId, util.Success(fmt.Sprintf("Archived ticket %s", ticket.Key)).
+		WithAction("View archive", "/todos/archive"))
+
+	h.get(w, r)
+}
+
+// unarchive puts a ticket back on the board and re-renders the archive it
+// was restored from.
+func (h *handler) unarchive(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	ticket, err := h.db.GetTicketByUser(r.Context(), userId, r.PathValue("id"))
+	if err != nil {
+		h.fail(w, r, userId, "Error fetching ticket", err, "ref", r.PathValue("id"))
+		return
+	}
+
+	if ticket.Archived() {
+		ticket.Unarchive(time.Now())
+		err = h.db.UpdateTicket(r.Context(), userId, ticket)
+		if err != nil {
+			h.fail(w, r, userId, "Error restoring ticket", err, "ticketId", ticket.Id)
+			return
+		}
+	}
+
+	h.nh.Notify(userId, util.Success(fmt.Sprintf("Restored ticket %s to the board", ticket.Key)).
+		WithAction("Open", "/todos/"+ticket.Id))
+
+	h.getArchive(w, r)
+}
+
+// getArchive renders a page of archived tickets, most recently archived
+// first, optionally narrowed down by a search query.
+func (h *handler) getArchive(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		// The board creates the user, there is nothing archived before that.
+		http.Redirect(w, r, "/todos", http.StatusSeeOther)
+		return
+	}
+
+	board, err := h.db.GetBoard(r.Context(), userId)
+	if err != nil {
+		h.fail(w, r, userId, "Error fetching tickets", err)
+		return
+	}
+
+	query := boardQuery(r)
+	filter := models.TicketFilter{
+		Archived: true,
+		Query:    query.Get("q"),
+	}
+	tickets := models.FilterTickets(board.Tickets, filter)
+	slices.SortStableFunc(tickets, func(a, b models.Ticket) int {
+		return b.ArchivedAt.Compare(*a.ArchivedAt)
+	})
+
+	pages := max(1, (len(tickets)+archivePageSize-1)/archivePageSize)
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	page = min(page, pages)
+
+	start := (page - 1) * archivePageSize
+	view := archiveView{
+		Query:   filter.Query,
+		Page:    page,
+		Pages:   pages,
+		Total:   len(tickets),
+		Tickets: tickets[start:min(start+archivePageSize, len(tickets))],
+	}
+
+	component := archivePage(r, view)
+	if htmx.WantsFragment(w, r) {
+		component = archivePanel(view)
+	}
+	component.Render(r.Context(), w)
+}
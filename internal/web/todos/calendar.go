@@ -0,0 +1,180 @@
+package todos
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/ical"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/util"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/htmx"
+)
+
+// calendarExt is the extension calendar feed URLs end with, some calendar
+// apps refuse to subscribe to URLs without it.
+const calendarExt = ".ics"
+
+// calendarView holds everything needed to render the calendar feed settings
+// of a board.
+type calendarView struct {
+	// URL is the URL of the calendar feed, empty while the board has none.
+	URL string
+}
+
+// calendarURL returns the absolute URL of the calendar feed with the given
+// token.
+func (h *handler) calendarURL(token string) string {
+	return h.baseURL + "/calendar/" + token + calendarExt
+}
+
+// getCalendar serves the tickets with a due date as an iCalendar feed of
+// to-dos to anyone holding the token of the board.
+func (h *handler) getCalendar(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutSuffix(r.PathValue("file"), calendarExt)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	boardId, err := h.db.GetCalendarBoardId(r.Context(), token)
+	if err != nil {
+		status := statusOf(err)
+		if status >= http.StatusInternalServerError {
+			h.log.Error("Error fetching calendar", "error", err.Error())
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	board, err := h.db.GetBoard(r.Context(), boardId)
+	if err != nil {
+		h.log.Error("Error fetching board", "boardId", boardId, "error", err.Error())
+		status := statusOf(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	cal := ical.Calendar{
+		ProdId: "-//LambdaBan//LambdaBan//EN",
+		Name:   "LambdaBan " + board.KeyPrefix,
+		Todos:  []ical.Todo{},
+	}
+	for _, t := range board.Tickets {
+		if t.DueAt == nil || t.Archived() {
+			continue
+		}
+		cal.Todos = append(cal.Todos, calendarTodo(t, h.baseURL+"/todos/"+t.Key))
+	}
+
+	w.Header().Set("Content-Type", ical.ContentType)
+	w.Header().Set("Content-Disposition", `inline; filename="lambdaban.ics"`)
+	w.Header().Set("Cache-Control", "no-store")
+	if err := cal.Write(w, time.Now()); err != nil {
+		h.log.Error("Error writing calendar", "boardId", boardId, "error", err.Error())
+	}
+}
+
+// calendarTodo turns a ticket with a due date into a to-do linking back to
+// it.
+func calendarTodo(t models.Ticket, url string) ical.Todo {
+	todo := ical.Todo{
+		UID:          t.Id + "@lambdaban",
+		Created:      t.CreatedAt,
+		LastModified: t.LastUpdatedAt,
+		Due:          *t.DueAt,
+		Summary:      t.Key + ": " + t.Title,
+		Description:  t.Description,
+		URL:          url,
+		Categories:   t.Labels,
+	}
+
+	switch t.Status {
+	case models.StatusTodo:
+		todo.Status = ical.StatusNeedsAction
+	case models.StatusInProgress:
+		todo.Status = ical.StatusInProcess
+	case models.StatusDone:
+		todo.Status = ical.StatusCompleted
+		todo.Completed, _ = t.CompletedAt()
+	}
+
+	switch t.Priority {
+	case models.PriorityUrgent:
+		todo.Priority = 1
+	case models.PriorityHigh:
+		todo.Priority = 3
+	case models.PriorityMedium:
+		todo.Priority = 5
+	case models.PriorityLow:
+		todo.Priority = 7
+	}
+
+	return todo
+}
+
+// getCalendarSettings shows the URL of the calendar feed of the board.
+func (h *handler) getCalendarSettings(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		// The board creates the user, there is nothing to subscribe to before that.
+		http.Redirect(w, r, "/todos", http.StatusSeeOther)
+		return
+	}
+
+	token, err := h.db.GetCalendarToken(r.Context(), userId)
+	if err != nil {
+		h.fail(w, r, userId, "Error fetching calendar", err)
+		return
+	}
+
+	v := calendarView{}
+	if token != "" {
+		v.URL = h.calendarURL(token)
+	}
+
+	component := calendarPage(r, v)
+	if htmx.WantsFragment(w, r) {
+		component = calendarPanel(v)
+	}
+	component.Render(r.Context(), w)
+}
+
+// postCalendar creates the calendar feed of the board, or gives it a new URL
+// when it already has one.
+func (h *handler) postCalendar(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	_, err := h.db.ResetCalendarToken(r.Context(), userId)
+	if err != nil {
+		h.fail(w, r, userId, "Error creating calendar link", err)
+		return
+	}
+
+	h.nh.Notify(userId, util.Success("Created calendar link"))
+
+	h.getCalendarSettings(w, r)
+}
+
+// deleteCalendar stops serving the calendar feed of the board.
+func (h *handler) deleteCalendar(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	err := h.db.DisableCalendarToken(r.Context(), userId)
+	if err != nil {
+		h.fail(w, r, userId, "Error disabling calendar link", err)
+		return
+	}
+
+	h.nh.Notify(userId, util.Success("Disabled calendar link"))
+
+	h.getCalendarSettings(w, r)
+}
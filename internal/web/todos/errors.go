@@ -0,0 +1,86 @@
+package todos
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/components"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/db"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/share"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/util"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/htmx"
+)
+
+// refreshBoardEvent makes the board reload itself.
+const refreshBoardEvent = "refreshBoard"
+
+var (
+	errNoSession  = errors.New("no user in session")
+	errBadRequest = errors.New("bad request")
+)
+
+// badRequest marks err as caused by the request rather than the server.
+func badRequest(err error) error {
+	return fmt.Errorf("%w: %w", errBadRequest, err)
+}
+
+// statusOf maps the error of a failed board action to a status code.
+func statusOf(err error) int {
+	var validationErrs models.ValidationErrors
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &validationErrs), errors.Is(err, errBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, errNoSession):
+		return http.StatusUnauthorized
+	case errors.Is(err, db.ErrTicketNotFound), errors.Is(err, db.ErrShareLinkNotFound),
+		errors.Is(err, db.ErrWebhookNotFound), errors.Is(err, db.ErrGitHubHookNotFound),
+		errors.Is(err, db.ErrCalendarNotFound), errors.Is(err, db.ErrFeedNotFound),
+		errors.Is(err, share.ErrInvalidToken), errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrConflict):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// fail responds to a failed board action with the status code of err. htmx
+// requests get an ActionError swapped into the Layout, the toast sent over
+// SSE is only a supplement to it.
+func (h *handler) fail(w http.ResponseWriter, r *http.Request, userId, message string, err error, args ...any) {
+	status := statusOf(err)
+
+	args = append(args, "userId", userId, "status", status, "error", err.Error())
+	if status >= http.StatusInternalServerError {
+		h.log.Error(message, args...)
+	} else {
+		h.log.Warn(message, args...)
+	}
+
+	if userId != "" {
+		h.nh.Notify(userId, util.Error(message))
+	}
+
+	if !htmx.IsRequest(r) {
+		w.WriteHeader(status)
+		component := components.ServerError(r, message)
+		component.Render(r.Context(), w)
+		return
+	}
+
+	// The board on the page is out of date, bring it back in line with what
+	// was actually saved.
+	if status == http.StatusConflict {
+		htmx.Trigger(w, refreshBoardEvent)
+	}
+
+	htmx.Retarget(w, "#action_errors", "innerHTML", "#action_error")
+	w.WriteHeader(status)
+	component := components.ActionError(message)
+	component.Render(r.Context(), w)
+}
@@ -0,0 +1,191 @@
+package todos
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/atom"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/util"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/htmx"
+	"github.com/google/uuid"
+)
+
+const (
+	// feedExt is the extension activity feed URLs end with.
+	feedExt = ".atom"
+	// maxFeedEntries caps the activity listed in the feed.
+	maxFeedEntries = 50
+)
+
+// feedView holds everything needed to render the activity feed settings of a
+// board.
+type feedView struct {
+	// URL is the URL of the activity feed, empty while the board has none.
+	URL string
+}
+
+// feedURL returns the absolute URL of the activity feed with the given
+// token.
+func (h *handler) feedURL(token string) string {
+	return h.baseURL + "/feed/" + token + feedExt
+}
+
+// getFeed serves the latest activity on the board as an Atom feed to anyone
+// holding the token of the board.
+func (h *handler) getFeed(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutSuffix(r.PathValue("file"), feedExt)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	boardId, err := h.db.GetFeedBoardId(r.Context(), token)
+	if err != nil {
+		status := statusOf(err)
+		if status >= http.StatusInternalServerError {
+			h.log.Error("Error fetching feed", "error", err.Error())
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	board, err := h.db.GetBoard(r.Context(), boardId)
+	if err != nil {
+		h.log.Error("Error fetching board", "boardId", boardId, "error", err.Error())
+		status := statusOf(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	activity, err := h.db.GetActivity(r.Context(), boardId, maxFeedEntries)
+	if err != nil {
+		h.log.Error("Error fetching activity", "boardId", boardId, "error", err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	feed := atom.Feed{
+		// The board id is kept out of the feed, the feed id only has to be
+		// stable.
+		Id:     "urn:uuid:" + uuid.NewSHA1(uuid.NameSpaceURL, []byte("lambdaban:board:"+boardId)).String(),
+		Title:  "LambdaBan " + board.KeyPrefix + " activity",
+		Author: atom.Person{Name: "LambdaBan"},
+		Links: []atom.Link{
+			{Href: h.feedURL(token), Rel: "self", Type: "application/atom+xml"},
+		},
+		Entries: make([]atom.Entry, 0, len(activity)),
+	}
+	// An empty feed was last updated whenever the board was created, which is
+	// not kept, the epoch keeps it stable.
+	updated := time.Unix(0, 0)
+	if len(activity) > 0 {
+		updated = activity[0].CreatedAt
+	}
+	feed.Updated = atom.Time(updated)
+	for _, a := range activity {
+		feed.Entries = append(feed.Entries, feedEntry(a, h.baseURL+"/todos/"+a.TicketKey))
+	}
+
+	w.Header().Set("Content-Type", atom.ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	if err := feed.Write(w); err != nil {
+		h.log.Error("Error writing feed", "boardId", boardId, "error", err.Error())
+	}
+}
+
+// feedEntry turns activity on a ticket into a feed entry, linking back to the
+// ticket unless it was deleted.
+func feedEntry(a models.Activity, url string) atom.Entry {
+	entry := atom.Entry{
+		Id:      "urn:uuid:" + a.Id,
+		Updated: atom.Time(a.CreatedAt),
+	}
+
+	switch a.Kind {
+	case models.ActivityCreated:
+		entry.Title = fmt.Sprintf("%s created: %s", a.TicketKey, a.Title)
+		entry.Summary = fmt.Sprintf("%s was created in %s.", a.TicketKey, statusTitle(a.To))
+	case models.ActivityMoved:
+		entry.Title = fmt.Sprintf("%s moved to %s: %s", a.TicketKey, statusTitle(a.To), a.Title)
+		entry.Summary = fmt.Sprintf("%s was moved from %s to %s.", a.TicketKey, statusTitle(a.From), statusTitle(a.To))
+	case models.ActivityCompleted:
+		entry.Title = fmt.Sprintf("%s completed: %s", a.TicketKey, a.Title)
+		entry.Summary = fmt.Sprintf("%s was moved from %s to %s.", a.TicketKey, statusTitle(a.From), statusTitle(a.To))
+	case models.ActivityDeleted:
+		entry.Title = fmt.Sprintf("%s deleted: %s", a.TicketKey, a.Title)
+		entry.Summary = fmt.Sprintf("%s was deleted from %s.", a.TicketKey, statusTitle(a.From))
+		return entry
+	}
+
+	entry.Links = []atom.Link{{Href: url, Rel: "alternate", Type: "text/html"}}
+	return entry
+}
+
+// getFeedSettings shows the URL of the activity feed of the board.
+func (h *handler) getFeedSettings(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		// The board creates the user, there is no activity to follow before that.
+		http.Redirect(w, r, "/todos", http.StatusSeeOther)
+		return
+	}
+
+	token, err := h.db.GetFeedToken(r.Context(), userId)
+	if err != nil {
+		h.fail(w, r, userId, "Error fetching feed", err)
+		return
+	}
+
+	v := feedView{}
+	if token != "" {
+		v.URL = h.feedURL(token)
+	}
+
+	component := feedPage(r, v)
+	if htmx.WantsFragment(w, r) {
+		component = feedPanel(v)
+	}
+	component.Render(r.Context(), w)
+}
+
+// postFeed creates the activity feed of the board, or gives it a new URL when
+// it already has one.
+func (h *handler) postFeed(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	_, err := h.db.ResetFeedToken(r.Context(), userId)
+	if err != nil {
+		h.fail(w, r, userId, "Error creating feed link", err)
+		return
+	}
+
+	h.nh.Notify(userId, util.Success("Created feed link"))
+
+	h.getFeedSettings(w, r)
+}
+
+// deleteFeed stops serving the activity feed of the board.
+func (h *handler) deleteFeed(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	err := h.db.DisableFeedToken(r.Context(), userId)
+	if err != nil {
+		h.fail(w, r, userId, "Error disabling feed link", err)
+		return
+	}
+
+	h.nh.Notify(userId, util.Success("Disabled feed link"))
+
+	h.getFeedSettings(w, r)
+}
@@ -2,111 +2,233 @@ package todos
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/JamesTiberiusKirk/lambdaban/internal/components"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/db"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/events"
 	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/share"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/util"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/htmx"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/notfound"
 	"github.com/JamesTiberiusKirk/lambdaban/internal/web/notifications"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/router"
 	"github.com/alexedwards/scs/v2"
 	"github.com/google/uuid"
 )
 
 type dbClient interface {
-	AddToUser(ctx context.Context, id string, ticket models.Ticket) error
+	AddToUser(ctx context.Context, id string, ticket models.Ticket) (models.Ticket, error)
+	BulkUpdate(ctx context.Context, userId string, ids []string, op models.BulkOperation) ([]models.Ticket, error)
 	CreateUser(ctx context.Context) (string, error)
-	DeleteTodoByUserAndTodoId(ctx context.Context, userId string, todoId string) error
-	GetAllByUser(ctx context.Context, id string) ([]models.Ticket, error)
-	GetAllByUserSplitByStatus(ctx context.Context, id string) (todo []models.Ticket, inProgress []models.Ticket, done []models.Ticket, err error)
-	UpdateUser(ctx context.Context, userId string, tickets []models.Ticket) error
+	DeleteTodoByUserAndTodoId(ctx context.Context, userId string, todoId string) (models.Ticket, error)
+	GetBoard(ctx context.Context, id string) (models.Board, error)
+	GetTicketByUser(ctx context.Context, userId string, ref string) (models.Ticket, error)
+	UpdateBoard(ctx context.Context, board models.Board) error
+	UpdateBoardSettings(ctx context.Context, userId string, settings models.BoardSettings) error
+	UpdateTicket(ctx context.Context, userId string, ticket models.Ticket) error
+
+	CreateShareLink(ctx context.Context, boardId string) (models.ShareLink, error)
+	GetShareLinks(ctx context.Context, boardId string) ([]models.ShareLink, error)
+	GetSharedBoardId(ctx context.Context, linkId string) (string, error)
+	RevokeShareLink(ctx context.Context, boardId, linkId string) error
+
+	CreateWebhook(ctx context.Context, webhook models.Webhook) (models.Webhook, error)
+	DeleteWebhook(ctx context.Context, boardId, id string) error
+	GetWebhook(ctx context.Context, boardId, id string) (models.Webhook, error)
+	GetWebhookDeliveries(ctx context.Context, webhookId string, limit int) ([]models.WebhookDelivery, error)
+	GetWebhooks(ctx context.Context, boardId string) ([]models.Webhook, error)
+	Redeliver(ctx context.Context, boardId, webhookId, deliveryId string) error
+
+	CreateGitHubHook(ctx context.Context, boardId string) (models.GitHubHook, error)
+	DeleteGitHubHook(ctx context.Context, boardId, id string) error
+	GetGitHubHooks(ctx context.Context, boardId string) ([]models.GitHubHook, error)
+
+	DisableCalendarToken(ctx context.Context, boardId string) error
+	GetCalendarBoardId(ctx context.Context, token string) (string, error)
+	GetCalendarToken(ctx context.Context, boardId string) (string, error)
+	ResetCalendarToken(ctx context.Context, boardId string) (string, error)
+
+	DisableFeedToken(ctx context.Context, boardId string) error
+	GetActivity(ctx context.Context, boardId string, limit int) ([]models.Activity, error)
+	GetFeedBoardId(ctx context.Context, token string) (string, error)
+	GetFeedToken(ctx context.Context, boardId string) (string, error)
+	ResetFeedToken(ctx context.Context, boardId string) (string, error)
 }
 
-func NewHandler(log *slog.Logger, db dbClient, sm *scs.SessionManager, nh *notifications.NotificationsHandler) http.Handler {
-	return &handler{
-		log: log,
-		db:  db,
-		sm:  sm,
-		nh:  nh,
+func NewHandler(
+	log *slog.Logger,
+	db dbClient,
+	sm *scs.SessionManager,
+	nh *notifications.NotificationsHandler,
+	events *events.Broker,
+	signer *share.Signer,
+	baseURL string,
+) http.Handler {
+	h := &handler{
+		log:     log,
+		db:      db,
+		sm:      sm,
+		nh:      nh,
+		events:  events,
+		signer:  signer,
+		baseURL: baseURL,
 	}
+
+	h.router = router.New(http.HandlerFunc(h.notFound), http.HandlerFunc(h.methodNotAllowed))
+	h.router.HandleFunc("GET /todos", h.get)
+	h.router.HandleFunc("POST /todos", h.post)
+	h.router.HandleFunc("GET /todos/{$}", h.redirectToBoard)
+	h.router.HandleFunc("POST /todos/session-reset", h.sessionReset)
+	h.router.HandleFunc("POST /todos/settings", h.postSettings)
+	h.router.HandleFunc("POST /todos/bulk", h.bulk)
+	h.router.HandleFunc("GET /todos/{id}", h.getTicket)
+	h.router.HandleFunc("PATCH /todos/{id}", h.patch)
+	h.router.HandleFunc("DELETE /todos/{id}", h.delete)
+	h.router.HandleFunc("GET /todos/{id}/edit", h.getEditForm)
+	h.router.HandleFunc("POST /todos/{id}/move", h.move)
+	h.router.HandleFunc("POST /todos/{id}/archive", h.archive)
+	h.router.HandleFunc("POST /todos/{id}/unarchive", h.unarchive)
+	h.router.HandleFunc("GET /todos/archive", h.getArchive)
+	h.router.HandleFunc("GET /todos/share", h.getShareLinks)
+	h.router.HandleFunc("POST /todos/share", h.postShareLink)
+	h.router.HandleFunc("POST /todos/share/{id}/revoke", h.revokeShareLink)
+	h.router.HandleFunc("GET /todos/webhooks", h.getWebhooks)
+	h.router.HandleFunc("POST /todos/webhooks", h.postWebhook)
+	h.router.HandleFunc("POST /todos/webhooks/{id}/delete", h.deleteWebhook)
+	h.router.HandleFunc("GET /todos/webhooks/{id}/deliveries", h.getWebhookDeliveries)
+	h.router.HandleFunc("POST /todos/webhooks/{id}/deliveries/{deliveryId}/redeliver", h.redeliver)
+	h.router.HandleFunc("POST /todos/webhooks/github", h.postGitHubHook)
+	h.router.HandleFunc("POST /todos/webhooks/github/{id}/delete", h.deleteGitHubHook)
+	h.router.HandleFunc("GET /todos/calendar", h.getCalendarSettings)
+	h.router.HandleFunc("POST /todos/calendar", h.postCalendar)
+	h.router.HandleFunc("POST /todos/calendar/delete", h.deleteCalendar)
+	h.router.HandleFunc("GET /todos/feed", h.getFeedSettings)
+	h.router.HandleFunc("POST /todos/feed", h.postFeed)
+	h.router.HandleFunc("POST /todos/feed/delete", h.deleteFeed)
+	h.router.HandleFunc("GET /share/{token}", h.getShared)
+	h.router.HandleFunc("GET /share/{token}/events", h.getSharedEvents)
+	h.router.HandleFunc("GET /calendar/{file}", h.getCalendar)
+	h.router.HandleFunc("GET /feed/{file}", h.getFeed)
+
+	return h
+}
+
+// boardView holds everything needed to render the board.
+type boardView struct {
+	Settings  models.BoardSettings
+	Swimlanes models.SwimlaneField
+	Lanes     []models.Swimlane
+	Filter    models.TicketFilter
+	Sort      models.TicketSort
+	// Username is the name of the account logged into, empty while the board
+	// is anonymous.
+	Username string
+}
+
+// boardQuery returns the query parameters the board is shown with. Changes
+// made through htmx post to their own URL, so the filters come from the URL of
+// the page instead.
+func boardQuery(r *http.Request) url.Values {
+	if r.Method != http.MethodGet {
+		if u := htmx.CurrentURL(r); u != nil {
+			return u.Query()
+		}
+	}
+	return r.URL.Query()
 }
 
 type handler struct {
-	version string
-	log     *slog.Logger
-	db      dbClient
-	sm      *scs.SessionManager
-	nh      *notifications.NotificationsHandler
+	log    *slog.Logger
+	db     dbClient
+	sm     *scs.SessionManager
+	nh     *notifications.NotificationsHandler
+	events *events.Broker
+	signer *share.Signer
+	// baseURL is the configured URL of the app, the links handed out are
+	// built from it so a forged Host header cannot point them elsewhere.
+	baseURL string
+	router  *router.Router
 }
 
+// dueDateError describes a due date which could not be parsed.
+const dueDateError = "Due date must be a date like 2006-01-02"
+
+// maxTicketFormBytes caps the size of a single ticket form submission.
+const maxTicketFormBytes = 64 << 10
+
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodGet && r.URL.String() == "/todos/" {
-		http.Redirect(w, r, "/todos", http.StatusPermanentRedirect)
-		return
-	}
+	h.router.ServeHTTP(w, r)
+}
 
-	if r.Method == http.MethodGet && r.URL.String() == "/todos/session-reset" {
-		h.sessionReset(w, r)
+func (h *handler) notFound(w http.ResponseWriter, r *http.Request) {
+	if htmx.IsRequest(r) {
+		h.fail(w, r, "", "Not found", db.ErrTicketNotFound)
 		return
 	}
 
-	if r.URL.String() != "/todos" && !strings.HasPrefix(r.URL.String(), "/todos?") {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
+	w.WriteHeader(http.StatusNotFound)
+	component := notfound.PageNotFound(r)
+	component.Render(r.Context(), w)
+}
 
-	switch r.Method {
-	case "GET":
-		h.get(w, r)
-		return
-	case "POST":
-		h.post(w, r)
-		return
-	case "PUT":
-		h.put(w, r)
-		return
-	case "DELETE":
-		h.delete(w, r)
-		return
-	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		return
-	}
+func (h *handler) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	component := components.MethodNotAllowed(r, w.Header().Get("Allow"))
+	component.Render(r.Context(), w)
+}
+
+func (h *handler) redirectToBoard(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/todos", http.StatusPermanentRedirect)
 }
 
 func (h *handler) sessionReset(w http.ResponseWriter, r *http.Request) {
 	h.log.Info("Resetting session")
 
 	userId := h.sm.GetString(r.Context(), "user")
-	h.nh.Notify(userId, notifications.Notification{
-		Type:    "Info",
-		Content: "Resetting session",
-	})
+	h.nh.Notify(userId, util.Info("Resetting session"))
 
+	// The new board is anonymous, so whoever was logged in no longer is.
 	h.sm.Remove(r.Context(), "user")
+	h.sm.Remove(r.Context(), "account")
+	h.sm.Remove(r.Context(), "username")
+	h.sm.Remove(r.Context(), "generation")
 	h.get(w, r)
 	return
 }
 
 func (h *handler) delete(w http.ResponseWriter, r *http.Request) {
 	userId := h.sm.GetString(r.Context(), "user")
-	r.ParseForm()
-
-	todoId := r.Form.Get("todo_id")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+	ref := r.PathValue("id")
 
-	h.log.Info("deleting", "userid", userId, "todoId", todoId)
+	ticket, err := h.db.GetTicketByUser(r.Context(), userId, ref)
+	if err != nil {
+		h.fail(w, r, userId, "Error removing ticket", err, "ref", ref)
+		return
+	}
 
-	err := h.db.DeleteTodoByUserAndTodoId(r.Context(), userId, todoId)
+	deleted, err := h.db.DeleteTodoByUserAndTodoId(r.Context(), userId, ticket.Id)
 	if err != nil {
-		h.log.Error("Error deleting todo ", "userId", userId, "todoId", todoId, "err", err.Error())
+		h.fail(w, r, userId, "Error removing ticket", err, "ticketId", ticket.Id)
+		return
 	}
 
-	h.nh.Notify(userId, notifications.Notification{
-		Type:    "Info",
-		Content: fmt.Sprintf("Removed ticket %s", todoId),
-	})
+	h.nh.Notify(userId, util.Success(fmt.Sprintf("Removed ticket %s", deleted.Key)))
 
 	h.get(w, r)
 }
@@ -127,145 +249,472 @@ func (h *handler) get(w http.ResponseWriter, r *http.Request) {
 		userId = uid
 
 		h.sm.Put(r.Context(), "user", userId)
-		h.nh.Notify(userId, notifications.Notification{
-			Type:    "Info",
-			Content: "New user",
-		})
+		h.nh.Notify(userId, util.Info("New user"))
 	}
 
-	todo, inProgrss, done, err := h.db.GetAllByUserSplitByStatus(r.Context(), userId)
+	board, err := h.db.GetBoard(r.Context(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The board was cleaned up after being inactive, start a new one.
+		h.sm.Remove(r.Context(), "user")
+		h.sm.Remove(r.Context(), "account")
+		h.sm.Remove(r.Context(), "username")
+		h.sm.Remove(r.Context(), "generation")
+		h.get(w, r)
+		return
+	}
 	if err != nil {
-		h.nh.Notify(userId, notifications.Notification{
-			Type:    "Error",
-			Content: "Error fetching tickets",
-		})
+		h.fail(w, r, userId, "Error fetching tickets", err)
+		return
 	}
 
-	h.log.Info("todos", "len", len(todo), "userid", userId)
+	h.log.Info("todos", "len", len(board.Tickets), "userid", userId)
+
+	query := boardQuery(r)
+	filter := models.ParseTicketFilter(query)
+	sort := models.ParseTicketSort(query.Get("sort"))
+	tickets := models.SortTickets(models.FilterTickets(board.Tickets, filter), sort)
+
+	swimlanes := models.ParseSwimlaneField(r.FormValue("swimlanes"))
+	view := boardView{
+		Settings:  board.Settings(),
+		Swimlanes: swimlanes,
+		Lanes:     models.SplitIntoSwimlanes(tickets, swimlanes),
+		Filter:    filter,
+		Sort:      sort,
+		Username:  h.sm.GetString(r.Context(), "username"),
+	}
+
+	component := page(r, view)
+	if htmx.WantsFragment(w, r) {
+		component = boardPanel(view)
+	}
 
 	w.WriteHeader(http.StatusOK)
-	component := page(r, userId, todo, inProgrss, done)
 	component.Render(r.Context(), w)
 }
 
-func (h *handler) post(w http.ResponseWriter, r *http.Request) {
-	defer h.get(w, r)
+// getTicket renders a single ticket, referenced either by its id or its key.
+func (h *handler) getTicket(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	ref := r.PathValue("id")
+	if userId == "" {
+		h.notFound(w, r)
+		return
+	}
+
+	ticket, err := h.db.GetTicketByUser(r.Context(), userId, ref)
+	if errors.Is(err, db.ErrTicketNotFound) || errors.Is(err, sql.ErrNoRows) {
+		h.notFound(w, r)
+		return
+	}
+	if err != nil {
+		h.log.Error("Error fetching ticket", "userId", userId, "ref", ref, "error", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		component := components.ServerError(r, "Unable to fetch ticket")
+		component.Render(r.Context(), w)
+		return
+	}
 
+	component := ticketPage(r, ticket)
+	component.Render(r.Context(), w)
+}
+
+func (h *handler) post(w http.ResponseWriter, r *http.Request) {
 	userId := h.sm.GetString(r.Context(), "user")
 	if userId == "" {
-		h.nh.Notify(userId, notifications.Notification{
-			Type:    "Error",
-			Content: "Error getting uid from session",
-		})
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
 		return
 	}
 
+	r.Body = http.MaxBytesReader(w, r.Body, maxTicketFormBytes)
+
 	// Update state.
 	err := r.ParseForm()
 	if err != nil {
-		h.log.Error("Error parsing form", "error", err.Error())
-		h.nh.Notify(userId, notifications.Notification{
-			Type:    "Error",
-			Content: "Error parsing form",
-		})
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.log.Warn("Ticket form too large", "userId", userId)
+			h.renderAddTicketForm(w, r, http.StatusRequestEntityTooLarge, models.Ticket{Status: models.StatusTodo}, models.ValidationErrors{
+				"form": "Ticket is too large",
+			})
+			return
+		}
+
+		h.fail(w, r, userId, "Error parsing form", badRequest(err))
 		return
 	}
 
 	newTodo := models.Ticket{}
 
-	newTodo.Title = r.Form.Get("title")
+	newTodo.Title = strings.TrimSpace(r.Form.Get("title"))
 	newTodo.Description = r.Form.Get("description")
-	newTodo.Status = models.Status(r.Form.Get("status"))
+	newTodo.Labels = models.ParseLabels(r.Form.Get("labels"))
+	newTodo.Priority = models.Priority(r.Form.Get("priority"))
+	newTodo.Assignee = strings.TrimSpace(r.Form.Get("assignee"))
 	newTodo.CreatedAt = time.Now()
-	newTodo.LastUpdatedAt = time.Now()
+	newTodo.MoveTo(models.Status(r.Form.Get("status")), newTodo.CreatedAt)
 	newTodo.Id = uuid.NewString()
 
-	err = h.db.AddToUser(r.Context(), userId, newTodo)
+	estimate, estimateErr := parseEstimate(r.Form.Get("estimate"))
+	newTodo.Estimate = estimate
+	dueAt, dueErr := models.ParseDueDate(r.Form.Get("due_date"))
+	newTodo.DueAt = dueAt
+
+	validationErrs := models.ValidationErrors{}
+	errors.As(newTodo.Validate(), &validationErrs)
+	if estimateErr != nil {
+		validationErrs["estimate"] = "Estimate must be a whole number"
+	}
+	if dueErr != nil {
+		validationErrs["due_date"] = dueDateError
+	}
+	if len(validationErrs) > 0 {
+		h.renderAddTicketForm(w, r, http.StatusBadRequest, newTodo, validationErrs)
+		return
+	}
+
+	newTodo, err = h.db.AddToUser(r.Context(), userId, newTodo)
 	if err != nil {
-		h.log.Error("Error adding tickets", "error", err.Error())
-		h.nh.Notify(userId, notifications.Notification{
-			Type:    "Error",
-			Content: "Error adding ticket",
-		})
+		h.fail(w, r, userId, "Error adding ticket", err)
 		return
 	}
 
 	h.sm.Put(r.Context(), "user", userId)
 
-	h.nh.Notify(userId, notifications.Notification{
-		Type:    "Info",
-		Content: fmt.Sprintf("Added ticket %s", newTodo.Id),
-	})
-}
+	h.nh.Notify(userId, util.Success(fmt.Sprintf("Added ticket %s", newTodo.Key)).
+		WithAction("Open", "/todos/"+newTodo.Id))
 
-func (h *handler) put(w http.ResponseWriter, r *http.Request) {
-	defer h.get(w, r)
+	h.get(w, r)
+}
 
+func (h *handler) postSettings(w http.ResponseWriter, r *http.Request) {
 	userId := h.sm.GetString(r.Context(), "user")
 	if userId == "" {
-		h.nh.Notify(userId, notifications.Notification{
-			Type:    "Error",
-			Content: "Error userId from session",
-		})
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
 		return
 	}
 
+	r.Body = http.MaxBytesReader(w, r.Body, maxTicketFormBytes)
+
 	err := r.ParseForm()
 	if err != nil {
-		h.log.Error("Error parsing form", "error", err.Error())
-		h.nh.Notify(userId, notifications.Notification{
-			Type:    "Error",
-			Content: "Error parsing form",
-		})
+		h.fail(w, r, userId, "Error parsing form", badRequest(err))
 		return
 	}
 
-	ids := r.Form["id"]
-	statuss := r.Form["status"]
+	archiveAfterDays, daysErr := strconv.Atoi(strings.TrimSpace(r.Form.Get("archive_after_days")))
+	settings := models.BoardSettings{
+		KeyPrefix:        strings.ToUpper(strings.TrimSpace(r.Form.Get("key_prefix"))),
+		ArchiveAfterDays: archiveAfterDays,
+	}
 
-	if len(ids) != len(statuss) {
-		h.log.Error("error id arr is not the same len as status")
+	validationErrs := models.ValidationErrors{}
+	errors.As(settings.Validate(), &validationErrs)
+	if daysErr != nil {
+		validationErrs["archive_after_days"] = "Must be a whole number of days"
+	}
+	if len(validationErrs) > 0 {
+		htmx.Retarget(w, "#boardSettings", "outerHTML", "#boardSettings")
+		w.WriteHeader(http.StatusBadRequest)
+		component := boardSettingsForm(settings, validationErrs)
+		component.Render(r.Context(), w)
 		return
 	}
 
-	currentTickets, err := h.db.GetAllByUser(r.Context(), userId)
+	err = h.db.UpdateBoardSettings(r.Context(), userId, settings)
 	if err != nil {
-		h.log.Error("error getting all user tickets", "error", err.Error())
-		h.nh.Notify(userId, notifications.Notification{
-			Type:    "Error",
-			Content: "Error fetching tickets",
-		})
+		h.fail(w, r, userId, "Error updating board settings", err)
+		return
+	}
+
+	h.nh.Notify(userId, util.Success(fmt.Sprintf("Board settings saved, ticket keys now start with %s", settings.KeyPrefix)))
+
+	h.get(w, r)
+}
+
+// parseEstimate parses the optional story point estimate of a ticket form, an
+// empty value means the ticket is not estimated.
+func parseEstimate(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// renderAddTicketForm re-renders the new ticket form in place of the one in
+// the dialogue so the user can correct their input.
+func (h *handler) renderAddTicketForm(w http.ResponseWriter, r *http.Request, status int, t models.Ticket, errs models.ValidationErrors) {
+	htmx.Retarget(w, "#newTicket", "outerHTML", "#newTicket")
+	w.WriteHeader(status)
+	component := addTicketForm(t, errs)
+	component.Render(r.Context(), w)
+}
+
+// getEditForm renders the edit form of a single ticket for the edit dialogue.
+func (h *handler) getEditForm(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	ref := r.PathValue("id")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
 		return
 	}
 
-	updatedTickets := []models.Ticket{}
+	ticket, err := h.db.GetTicketByUser(r.Context(), userId, ref)
+	if err != nil {
+		h.fail(w, r, userId, "Error fetching ticket", err, "ref", ref)
+		return
+	}
 
-	// to avoid dups
-	done := map[string]bool{}
-	for i, id := range ids {
-		for _, t := range currentTickets {
-			if t.Id != id {
-				continue
-			}
+	component := editTicketForm(ticket, nil)
+	component.Render(r.Context(), w)
+}
 
-			if done[t.Id] {
-				continue
-			}
+// patch updates only the fields of a ticket which are present in the form.
+func (h *handler) patch(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
 
-			t.Status = models.Status(statuss[i])
-			done[t.Id] = true
+	r.Body = http.MaxBytesReader(w, r.Body, maxTicketFormBytes)
 
-			updatedTickets = append(updatedTickets, t)
+	err := r.ParseForm()
+	if err != nil {
+		h.fail(w, r, userId, "Error parsing form", badRequest(err))
+		return
+	}
+
+	ticket, err := h.db.GetTicketByUser(r.Context(), userId, r.PathValue("id"))
+	if err != nil {
+		h.fail(w, r, userId, "Error fetching ticket", err, "ref", r.PathValue("id"))
+		return
+	}
+
+	// The form carries the time the ticket was last updated when it was
+	// opened, so changes made since are not silently overwritten.
+	if seen := r.Form.Get("last_updated_at"); seen != "" && seen != ticket.LastUpdatedAt.Format(time.RFC3339Nano) {
+		h.fail(w, r, userId, fmt.Sprintf("Ticket %s was changed since it was opened, reopen it to edit", ticket.Key), db.ErrConflict, "ticketId", ticket.Id)
+		return
+	}
+
+	lastUpdatedAt := ticket.LastUpdatedAt
+	patch, parseErrs := parseTicketPatch(r.Form)
+	patch.Apply(&ticket, time.Now())
+	validationErrs := models.ValidationErrors{}
+	errors.As(ticket.Validate(), &validationErrs)
+	maps.Copy(validationErrs, parseErrs)
+	if len(validationErrs) > 0 {
+		ticket.LastUpdatedAt = lastUpdatedAt
+		htmx.Retarget(w, "#editTicket", "outerHTML", "#editTicket")
+		w.WriteHeader(http.StatusBadRequest)
+		component := editTicketForm(ticket, validationErrs)
+		component.Render(r.Context(), w)
+		return
+	}
+
+	err = h.db.UpdateTicket(r.Context(), userId, ticket)
+	if err != nil {
+		h.fail(w, r, userId, "Error updating ticket", err, "ticketId", ticket.Id)
+		return
+	}
+
+	h.nh.Notify(userId, util.Success(fmt.Sprintf("Updated ticket %s", ticket.Key)).
+		WithAction("Open", "/todos/"+ticket.Id))
+
+	h.get(w, r)
+}
+
+// parseTicketPatch builds a patch from the ticket fields present in the form.
+// Values which cannot be parsed are reported as validation errors.
+func parseTicketPatch(form url.Values) (models.TicketPatch, models.ValidationErrors) {
+	patch := models.TicketPatch{}
+	errs := models.ValidationErrors{}
+
+	if form.Has("title") {
+		title := strings.TrimSpace(form.Get("title"))
+		patch.Title = &title
+	}
+	if form.Has("description") {
+		description := form.Get("description")
+		patch.Description = &description
+	}
+	if form.Has("status") {
+		status := models.Status(form.Get("status"))
+		patch.Status = &status
+	}
+	if form.Has("labels") {
+		labels := models.ParseLabels(form.Get("labels"))
+		patch.Labels = &labels
+	}
+	if form.Has("priority") {
+		priority := models.Priority(form.Get("priority"))
+		patch.Priority = &priority
+	}
+	if form.Has("assignee") {
+		assignee := strings.TrimSpace(form.Get("assignee"))
+		patch.Assignee = &assignee
+	}
+	if form.Has("estimate") {
+		estimate, err := parseEstimate(form.Get("estimate"))
+		if err != nil {
+			errs["estimate"] = "Estimate must be a whole number"
+		} else {
+			patch.Estimate = &estimate
+		}
+	}
+	if form.Has("due_date") {
+		dueAt, err := models.ParseDueDate(form.Get("due_date"))
+		switch {
+		case err != nil:
+			errs["due_date"] = dueDateError
+		case dueAt == nil:
+			patch.DueAt = &time.Time{}
+		default:
+			patch.DueAt = dueAt
 		}
 	}
 
-	err = h.db.UpdateUser(r.Context(), userId, updatedTickets)
+	return patch, errs
+}
+
+// move moves a ticket to another status, lane and position on the board.
+func (h *handler) move(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+
+	// The card was already dropped in its new place on the page, put it
+	// back where it really is when the move fails.
+	fail := func(message string, err error, args ...any) {
+		htmx.Trigger(w, refreshBoardEvent)
+		h.fail(w, r, userId, message, err, args...)
+	}
+
+	if userId == "" {
+		fail("Error getting uid from session", errNoSession)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxTicketFormBytes)
+
+	err := r.ParseForm()
+	if err != nil {
+		fail("Error parsing form", badRequest(err))
+		return
+	}
+
+	status := models.Status(r.Form.Get("status"))
+	swimlanes := models.ParseSwimlaneField(r.Form.Get("swimlanes"))
+	lane := r.Form.Get("lane")
+	index, err := strconv.Atoi(r.Form.Get("index"))
+	if err != nil {
+		index = 0
+	}
+
+	board, err := h.db.GetBoard(r.Context(), userId)
 	if err != nil {
-		h.log.Error("error updating tickets", "error", err.Error())
-		h.nh.Notify(userId, notifications.Notification{
-			Type:    "Error",
-			Content: "Error updating tickets",
-		})
+		fail("Error fetching tickets", err)
 		return
 	}
+
+	ticket, err := h.db.GetTicketByUser(r.Context(), userId, r.PathValue("id"))
+	if err != nil {
+		fail("Error fetching ticket", err, "ref", r.PathValue("id"))
+		return
+	}
+
+	query := boardQuery(r)
+	index = models.VisibleMoveIndex(board.Tickets, ticket.Id, status, swimlanes, lane,
+		models.ParseTicketFilter(query), models.ParseTicketSort(query.Get("sort")), index)
+
+	tickets, moved, ok := models.MoveTicket(board.Tickets, ticket.Id, status, swimlanes, lane, index, time.Now())
+	if !ok {
+		fail("Error moving ticket", db.ErrTicketNotFound, "ticketId", ticket.Id)
+		return
+	}
+	if !moved.Status.Valid() || !moved.Priority.Valid() {
+		fail("Invalid ticket move", badRequest(fmt.Errorf("status %q priority %q", moved.Status, moved.Priority)), "ticketId", moved.Id)
+		return
+	}
+
+	board.Tickets = tickets
+	err = h.db.UpdateBoard(r.Context(), board)
+	if err != nil {
+		fail("Error moving ticket", err)
+		return
+	}
+
+	h.get(w, r)
+}
+
+// bulk applies one action to all of the selected tickets.
+func (h *handler) bulk(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxTicketFormBytes)
+
+	err := r.ParseForm()
+	if err != nil {
+		h.fail(w, r, userId, "Error parsing form", badRequest(err))
+		return
+	}
+
+	ids := r.Form["ids"]
+	if len(ids) == 0 {
+		h.fail(w, r, userId, "Select at least one ticket", badRequest(errors.New("no tickets selected")))
+		return
+	}
+
+	op := models.BulkOperation{
+		Action: models.BulkAction(r.Form.Get("action")),
+		Status: models.Status(r.Form.Get("status")),
+		Label:  r.Form.Get("label"),
+	}
+
+	var validationErrs models.ValidationErrors
+	if errors.As(op.Validate(), &validationErrs) {
+		h.fail(w, r, userId, strings.Join(slices.Collect(maps.Values(validationErrs)), ", "), validationErrs)
+		return
+	}
+
+	affected, err := h.db.BulkUpdate(r.Context(), userId, ids, op)
+	if err != nil {
+		h.fail(w, r, userId, "Error updating the selected tickets", err, "action", op.Action)
+		return
+	}
+
+	h.nh.Notify(userId, util.Success(bulkSummary(op, affected)))
+
+	h.get(w, r)
+}
+
+// bulkSummary describes a bulk operation in a single notification.
+func bulkSummary(op models.BulkOperation, affected []models.Ticket) string {
+	keys := make([]string, 0, len(affected))
+	for _, t := range affected {
+		keys = append(keys, t.Key)
+	}
+
+	tickets := fmt.Sprintf("%d tickets", len(affected))
+	if len(affected) == 1 {
+		tickets = "1 ticket"
+	}
+
+	var summary string
+	switch op.Action {
+	case models.BulkMove:
+		summary = fmt.Sprintf("Moved %s to %s", tickets, statusTitle(op.Status))
+	case models.BulkDelete:
+		summary = fmt.Sprintf("Removed %s", tickets)
+	case models.BulkLabel:
+		summary = fmt.Sprintf("Labelled %s with %s", tickets, strings.TrimSpace(op.Label))
+	case models.BulkArchive:
+		summary = fmt.Sprintf("Archived %s", tickets)
+	}
+
+	return summary + ": " + strings.Join(keys, ", ")
 }
@@ -0,0 +1,37 @@
+package todos
+
+import (
+	"context"
+	"io"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+)
+
+// BoardRenderer renders the changes of a board for the notifications stream
+// of its owner. Only boards shown as they are stored, without filters, sorting
+// or swimlanes, are updated in place, every other view refreshes itself.
+type BoardRenderer struct {
+	db dbClient
+}
+
+func NewBoardRenderer(db dbClient) *BoardRenderer {
+	return &BoardRenderer{db: db}
+}
+
+// RenderBoardUpdate renders every column of the board as an out-of-band swap.
+func (b *BoardRenderer) RenderBoardUpdate(ctx context.Context, w io.Writer, boardId string) error {
+	board, err := b.db.GetBoard(ctx, boardId)
+	if err != nil {
+		return err
+	}
+
+	tickets := models.FilterTickets(board.Tickets, models.TicketFilter{})
+	lanes := models.SplitIntoSwimlanes(tickets, models.SwimlaneNone)
+	return boardUpdate(lanes[0]).Render(ctx, w)
+}
+
+// liveSync reports whether the board is shown as it is stored, so the columns
+// sent by the BoardRenderer can be swapped straight in.
+func (v boardView) liveSync() bool {
+	return v.Filter.IsEmpty() && v.Sort == models.SortManual && v.Swimlanes == models.SwimlaneNone
+}
@@ -0,0 +1,217 @@
+package todos
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/components"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/db"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/util"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/htmx"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/notfound"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/notifications"
+)
+
+// sharedBoardEvent is the SSE event telling a shared board to reload itself.
+const sharedBoardEvent = "board"
+
+// shareLinkView is a share link along with the URL it is reached at.
+type shareLinkView struct {
+	Link models.ShareLink
+	URL  string
+}
+
+// shareURL returns the absolute URL of the shared board with the given
+// token.
+func (h *handler) shareURL(token string) string {
+	return h.baseURL + "/share/" + token
+}
+
+// sharedBoardId returns the id of the board the token of the request shares.
+func (h *handler) sharedBoardId(r *http.Request) (string, error) {
+	linkId, err := h.signer.Verify(r.PathValue("token"))
+	if err != nil {
+		return "", err
+	}
+	return h.db.GetSharedBoardId(r.Context(), linkId)
+}
+
+// getShared renders the shared board read-only to anyone holding a valid
+// token.
+func (h *handler) getShared(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	boardId, err := h.sharedBoardId(r)
+	if err != nil {
+		h.sharedNotFound(w, r, err)
+		return
+	}
+
+	board, err := h.db.GetBoard(r.Context(), boardId)
+	if err != nil {
+		h.sharedNotFound(w, r, err)
+		return
+	}
+
+	filter := models.TicketFilter{}
+	swimlanes := models.ParseSwimlaneField(r.FormValue("swimlanes"))
+	view := boardView{
+		Settings:  board.Settings(),
+		Swimlanes: swimlanes,
+		Lanes:     models.SplitIntoSwimlanes(models.FilterTickets(board.Tickets, filter), swimlanes),
+		Filter:    filter,
+	}
+
+	component := sharedPage(r, token, view)
+	if htmx.WantsFragment(w, r) {
+		component = sharedBoardPanel(token, view)
+	}
+	component.Render(r.Context(), w)
+}
+
+// sharedNotFound responds to a request for a board which is not shared, or
+// no longer, as if it never existed.
+func (h *handler) sharedNotFound(w http.ResponseWriter, r *http.Request, err error) {
+	if statusOf(err) != http.StatusNotFound {
+		h.log.Error("Error fetching shared board", "error", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		component := components.ServerError(r, "Unable to fetch board")
+		component.Render(r.Context(), w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	component := notfound.PageNotFound(r)
+	component.Render(r.Context(), w)
+}
+
+// getSharedEvents streams an event every time the shared board changes, so
+// viewers see the board as it is without reloading. The stream ends once the
+// link is revoked.
+func (h *handler) getSharedEvents(w http.ResponseWriter, r *http.Request) {
+	linkId, err := h.signer.Verify(r.PathValue("token"))
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	boardId, err := h.db.GetSharedBoardId(r.Context(), linkId)
+	if err != nil {
+		status := statusOf(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		h.log.Error("Streaming unsupported")
+		return
+	}
+
+	events, cancel := h.events.Subscribe(boardId)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	notifications.WriteRetry(w)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(notifications.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			notifications.WriteHeartbeat(w)
+			flusher.Flush()
+		case <-events:
+			_, err := h.db.GetSharedBoardId(ctx, linkId)
+			if errors.Is(err, db.ErrShareLinkNotFound) {
+				return
+			}
+			if err != nil {
+				h.log.Error("Error checking share link", "linkId", linkId, "error", err.Error())
+				continue
+			}
+
+			fmt.Fprintf(w, "event: %s\ndata: \n\n", sharedBoardEvent)
+			flusher.Flush()
+		}
+	}
+}
+
+// getShareLinks lists the share links of the board.
+func (h *handler) getShareLinks(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		// The board creates the user, there is nothing to share before that.
+		http.Redirect(w, r, "/todos", http.StatusSeeOther)
+		return
+	}
+
+	links, err := h.db.GetShareLinks(r.Context(), userId)
+	if err != nil {
+		h.fail(w, r, userId, "Error fetching share links", err)
+		return
+	}
+
+	views := make([]shareLinkView, 0, len(links))
+	for _, l := range links {
+		views = append(views, shareLinkView{
+			Link: l,
+			URL:  h.shareURL(h.signer.Sign(l.Id)),
+		})
+	}
+
+	component := sharePage(r, views)
+	if htmx.WantsFragment(w, r) {
+		component = sharePanel(views)
+	}
+	component.Render(r.Context(), w)
+}
+
+// postShareLink creates a new share link for the board.
+func (h *handler) postShareLink(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	_, err := h.db.CreateShareLink(r.Context(), userId)
+	if err != nil {
+		h.fail(w, r, userId, "Error creating share link", err)
+		return
+	}
+
+	h.nh.Notify(userId, util.Success("Created share link"))
+
+	h.getShareLinks(w, r)
+}
+
+// revokeShareLink revokes a share link of the board, anyone viewing the board
+// through it stops getting updates.
+func (h *handler) revokeShareLink(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	err := h.db.RevokeShareLink(r.Context(), userId, r.PathValue("id"))
+	if err != nil {
+		h.fail(w, r, userId, "Error revoking share link", err, "linkId", r.PathValue("id"))
+		return
+	}
+
+	h.nh.Notify(userId, util.Success("Revoked share link"))
+
+	h.getShareLinks(w, r)
+}
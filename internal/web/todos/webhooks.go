@@ -0,0 +1,229 @@
+package todos
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/util"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/web/htmx"
+)
+
+// maxWebhookDeliveries caps the deliveries shown in the delivery log.
+const maxWebhookDeliveries = 50
+
+// webhooksView holds everything needed to render the webhooks of a board.
+type webhooksView struct {
+	Webhooks    []models.Webhook
+	GitHubHooks []gitHubHookView
+}
+
+// gitHubHookView is a GitHub endpoint along with the URL GitHub posts to.
+type gitHubHookView struct {
+	Hook models.GitHubHook
+	URL  string
+}
+
+// webhookDeliveriesView holds everything needed to render the delivery log of
+// a webhook.
+type webhookDeliveriesView struct {
+	Webhook    models.Webhook
+	Deliveries []models.WebhookDelivery
+}
+
+// getWebhooks lists the webhooks of the board along with the form adding
+// new ones.
+func (h *handler) getWebhooks(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		// The board creates the user, there is nothing to hook into before that.
+		http.Redirect(w, r, "/todos", http.StatusSeeOther)
+		return
+	}
+
+	webhooks, err := h.db.GetWebhooks(r.Context(), userId)
+	if err != nil {
+		h.fail(w, r, userId, "Error fetching webhooks", err)
+		return
+	}
+
+	gitHubHooks, err := h.db.GetGitHubHooks(r.Context(), userId)
+	if err != nil {
+		h.fail(w, r, userId, "Error fetching GitHub endpoints", err)
+		return
+	}
+
+	v := webhooksView{
+		Webhooks:    webhooks,
+		GitHubHooks: make([]gitHubHookView, 0, len(gitHubHooks)),
+	}
+	for _, hook := range gitHubHooks {
+		v.GitHubHooks = append(v.GitHubHooks, gitHubHookView{
+			Hook: hook,
+			URL:  h.baseURL + "/hooks/github/" + hook.Id,
+		})
+	}
+
+	component := webhooksPage(r, v)
+	if htmx.WantsFragment(w, r) {
+		component = webhooksPanel(v, models.Webhook{}, nil)
+	}
+	component.Render(r.Context(), w)
+}
+
+// postWebhook adds a webhook to the board.
+func (h *handler) postWebhook(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxTicketFormBytes)
+
+	err := r.ParseForm()
+	if err != nil {
+		h.fail(w, r, userId, "Error parsing form", badRequest(err))
+		return
+	}
+
+	webhook := models.Webhook{
+		BoardId: userId,
+		URL:     strings.TrimSpace(r.Form.Get("url")),
+	}
+	for _, e := range r.Form["events"] {
+		webhook.Events = append(webhook.Events, models.TicketEvent(e))
+	}
+
+	validationErrs := models.ValidationErrors{}
+	if errors.As(webhook.Validate(), &validationErrs) {
+		htmx.Retarget(w, "#webhook_form", "outerHTML", "#webhook_form")
+		w.WriteHeader(http.StatusBadRequest)
+		component := webhookForm(webhook, validationErrs)
+		component.Render(r.Context(), w)
+		return
+	}
+
+	_, err = h.db.CreateWebhook(r.Context(), webhook)
+	if err != nil {
+		h.fail(w, r, userId, "Error creating webhook", err)
+		return
+	}
+
+	h.nh.Notify(userId, util.Success("Added webhook for "+webhook.URL))
+
+	h.getWebhooks(w, r)
+}
+
+// deleteWebhook removes a webhook from the board, it is sent nothing more.
+func (h *handler) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	err := h.db.DeleteWebhook(r.Context(), userId, r.PathValue("id"))
+	if err != nil {
+		h.fail(w, r, userId, "Error deleting webhook", err, "webhookId", r.PathValue("id"))
+		return
+	}
+
+	h.nh.Notify(userId, util.Success("Deleted webhook"))
+
+	h.getWebhooks(w, r)
+}
+
+// postGitHubHook creates a new endpoint for GitHub to post the issue events of
+// a repository to.
+func (h *handler) postGitHubHook(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	_, err := h.db.CreateGitHubHook(r.Context(), userId)
+	if err != nil {
+		h.fail(w, r, userId, "Error creating GitHub endpoint", err)
+		return
+	}
+
+	h.nh.Notify(userId, util.Success("Created GitHub endpoint"))
+
+	h.getWebhooks(w, r)
+}
+
+// deleteGitHubHook removes a GitHub endpoint, GitHub's deliveries to it fail
+// from then on.
+func (h *handler) deleteGitHubHook(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	err := h.db.DeleteGitHubHook(r.Context(), userId, r.PathValue("id"))
+	if err != nil {
+		h.fail(w, r, userId, "Error deleting GitHub endpoint", err, "hookId", r.PathValue("id"))
+		return
+	}
+
+	h.nh.Notify(userId, util.Success("Deleted GitHub endpoint"))
+
+	h.getWebhooks(w, r)
+}
+
+// getWebhookDeliveries shows the latest deliveries of a webhook and how they
+// went.
+func (h *handler) getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		http.Redirect(w, r, "/todos", http.StatusSeeOther)
+		return
+	}
+
+	webhook, err := h.db.GetWebhook(r.Context(), userId, r.PathValue("id"))
+	if err != nil {
+		h.fail(w, r, userId, "Error fetching webhook", err, "webhookId", r.PathValue("id"))
+		return
+	}
+
+	deliveries, err := h.db.GetWebhookDeliveries(r.Context(), webhook.Id, maxWebhookDeliveries)
+	if err != nil {
+		h.fail(w, r, userId, "Error fetching webhook deliveries", err, "webhookId", webhook.Id)
+		return
+	}
+
+	v := webhookDeliveriesView{
+		Webhook:    webhook,
+		Deliveries: deliveries,
+	}
+
+	component := webhookDeliveriesPage(r, v)
+	if htmx.WantsFragment(w, r) {
+		component = webhookDeliveriesPanel(v)
+	}
+	component.Render(r.Context(), w)
+}
+
+// redeliver sends the payload of a delivery to its webhook again.
+func (h *handler) redeliver(w http.ResponseWriter, r *http.Request) {
+	userId := h.sm.GetString(r.Context(), "user")
+	if userId == "" {
+		h.fail(w, r, userId, "Error getting uid from session", errNoSession)
+		return
+	}
+
+	err := h.db.Redeliver(r.Context(), userId, r.PathValue("id"), r.PathValue("deliveryId"))
+	if err != nil {
+		h.fail(w, r, userId, "Error redelivering webhook", err,
+			"webhookId", r.PathValue("id"), "deliveryId", r.PathValue("deliveryId"))
+		return
+	}
+
+	h.nh.Notify(userId, util.Info("Queued redelivery"))
+
+	h.getWebhookDeliveries(w, r)
+}
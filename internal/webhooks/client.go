@@ -0,0 +1,32 @@
+package webhooks
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/netguard"
+)
+
+// NewClient returns the client deliveries are posted with. Webhook URLs are
+// chosen by users, so it only connects to public addresses, ignores proxy
+// settings which would hide the address connected to, and does not follow
+// redirects which could point anywhere.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: netguard.Control,
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,201 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/metrics"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+)
+
+const (
+	// SignatureHeader holds the HMAC-SHA256 of the body keyed with the secret
+	// of the webhook, as "sha256=<hex>".
+	SignatureHeader = "X-LambdaBan-Signature-256"
+	// EventHeader holds the ticket event of the delivery.
+	EventHeader = "X-LambdaBan-Event"
+	// DeliveryHeader holds the id of the delivery, which stays the same
+	// across its attempts.
+	DeliveryHeader = "X-LambdaBan-Delivery"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it is given up
+	// on.
+	MaxAttempts = 8
+	// baseBackoff is how long to wait before the second attempt, every
+	// attempt after it waits twice as long as the one before.
+	baseBackoff = 30 * time.Second
+	// maxBackoff caps the wait between attempts.
+	maxBackoff = time.Hour
+	// batchSize is how many deliveries are claimed at once.
+	batchSize = 20
+	// lease is how long claimed deliveries are kept from other workers, long
+	// enough for the whole batch to time out.
+	lease = 5 * time.Minute
+	// maxErrorLength caps the error kept for an attempt.
+	maxErrorLength = 500
+)
+
+type dbClient interface {
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.DueDelivery, error)
+	RecordDeliveryAttempt(ctx context.Context, id string, attempt models.DeliveryAttempt) error
+}
+
+// Worker posts the queued deliveries to their webhooks, trying failed ones
+// again with exponential backoff.
+type Worker struct {
+	log    *slog.Logger
+	m      *metrics.Metrics
+	db     dbClient
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewWorker creates a worker sending deliveries with client, which should
+// have a timeout.
+func NewWorker(log *slog.Logger, m *metrics.Metrics, db dbClient, client *http.Client, now func() time.Time) *Worker {
+	return &Worker{
+		log:    log,
+		m:      m,
+		db:     db,
+		client: client,
+		now:    now,
+	}
+}
+
+// Start starts a background goroutine sending the due deliveries at the given
+// interval. It stops when the provided context is cancelled.
+func (w *Worker) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := w.RunOnce(ctx)
+				if err != nil {
+					w.log.Error("Webhook deliveries failed", "error", err)
+				} else if n > 0 {
+					w.log.Info("Webhook deliveries ran successfully", "deliveries", n)
+				}
+			case <-ctx.Done():
+				w.log.Info("Webhook worker stopped")
+				return
+			}
+		}
+	}()
+}
+
+// RunOnce sends the deliveries which are due, batch by batch, until there are
+// none left. It returns how many were attempted.
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	n := 0
+	for {
+		deliveries, err := w.db.ClaimDueDeliveries(ctx, batchSize, lease)
+		if err != nil {
+			return n, err
+		}
+		for _, d := range deliveries {
+			attempt := w.deliver(ctx, d)
+			if err := w.db.RecordDeliveryAttempt(ctx, d.Id, attempt); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if len(deliveries) < batchSize {
+			return n, nil
+		}
+	}
+}
+
+// deliver makes a single attempt at posting the delivery, working out when to
+// try again should it fail.
+func (w *Worker) deliver(ctx context.Context, d models.DueDelivery) models.DeliveryAttempt {
+	statusCode, err := w.post(ctx, d)
+	attempt := models.DeliveryAttempt{
+		At:         w.now(),
+		StatusCode: statusCode,
+	}
+	if err == nil {
+		attempt.Delivered = true
+		w.m.WebhookDeliveryAttemptsTotal.WithLabelValues("delivered").Inc()
+		return attempt
+	}
+
+	attempt.Error = err.Error()
+	if len(attempt.Error) > maxErrorLength {
+		attempt.Error = attempt.Error[:maxErrorLength]
+	}
+
+	attempts := d.Attempts + 1
+	if attempts >= MaxAttempts {
+		w.log.Warn("Giving up on webhook delivery", "deliveryId", d.Id, "webhookId", d.WebhookId, "attempts", attempts, "error", err)
+		w.m.WebhookDeliveryAttemptsTotal.WithLabelValues("failed").Inc()
+		return attempt
+	}
+
+	next := attempt.At.Add(Backoff(attempts))
+	attempt.NextAttemptAt = &next
+	w.m.WebhookDeliveryAttemptsTotal.WithLabelValues("retry").Inc()
+	return attempt
+}
+
+// post sends the delivery to its webhook, any response other than a 2xx is
+// an error.
+func (w *Worker) post(ctx context.Context, d models.DueDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "LambdaBan-Webhooks")
+	req.Header.Set(EventHeader, d.Event.String())
+	req.Header.Set(DeliveryHeader, d.Id)
+	req.Header.Set(SignatureHeader, Sign(d.Secret, d.Payload))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	// Drain a little of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(res.Body, 4<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// Sign returns the value of SignatureHeader for the body, receivers compute
+// the same from the body and the secret of the webhook to check it.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns how long to wait before trying a delivery again after the
+// given number of failed attempts.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	d := baseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
@@ -0,0 +1,179 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JamesTiberiusKirk/lambdaban/internal/metrics"
+	"github.com/JamesTiberiusKirk/lambdaban/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeDB keeps deliveries in memory, handing out the ones whose next attempt
+// is due by the clock of the test the way the database does.
+type fakeDB struct {
+	mu         sync.Mutex
+	now        func() time.Time
+	deliveries []*models.DueDelivery
+	attempts   []models.DeliveryAttempt
+}
+
+func (f *fakeDB) ClaimDueDeliveries(_ context.Context, limit int, _ time.Duration) ([]models.DueDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	due := []models.DueDelivery{}
+	for _, d := range f.deliveries {
+		if len(due) == limit {
+			break
+		}
+		if d.NextAttemptAt != nil && !d.NextAttemptAt.After(f.now()) {
+			due = append(due, *d)
+		}
+	}
+	return due, nil
+}
+
+func (f *fakeDB) RecordDeliveryAttempt(_ context.Context, id string, attempt models.DeliveryAttempt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts = append(f.attempts, attempt)
+	for _, d := range f.deliveries {
+		if d.Id != id {
+			continue
+		}
+		d.Attempts++
+		d.StatusCode = attempt.StatusCode
+		d.Error = attempt.Error
+		d.NextAttemptAt = attempt.NextAttemptAt
+		if attempt.Delivered {
+			at := attempt.At
+			d.DeliveredAt = &at
+		}
+	}
+	return nil
+}
+
+// newTestWorker returns a worker sending to a server answering with the given
+// handler, along with the fake database and a function moving its clock on.
+func newTestWorker(t *testing.T, handler http.HandlerFunc) (*Worker, *fakeDB, func(time.Duration)) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	fake := &fakeDB{now: clock}
+	fake.deliveries = []*models.DueDelivery{{
+		WebhookDelivery: models.WebhookDelivery{
+			Id:            "delivery",
+			WebhookId:     "webhook",
+			Event:         models.TicketCreated,
+			Payload:       []byte(`{"event":"ticket.created"}`),
+			NextAttemptAt: &now,
+		},
+		URL:    srv.URL,
+		Secret: "secret",
+	}}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	w := NewWorker(log, metrics.NewMetrics(prometheus.NewRegistry()), fake, srv.Client(), clock)
+	return w, fake, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestWorkerSignsDelivery(t *testing.T) {
+	var got http.Header
+	var body []byte
+	w, fake, _ := newTestWorker(t, func(rw http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+	})
+
+	n, err := w.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("attempted %d deliveries, want 1", n)
+	}
+
+	if sig := got.Get(SignatureHeader); sig != Sign("secret", body) {
+		t.Errorf("%s = %q, want %q", SignatureHeader, sig, Sign("secret", body))
+	}
+	if event := got.Get(EventHeader); event != models.TicketCreated.String() {
+		t.Errorf("%s = %q, want %q", EventHeader, event, models.TicketCreated)
+	}
+	if id := got.Get(DeliveryHeader); id != "delivery" {
+		t.Errorf("%s = %q, want %q", DeliveryHeader, id, "delivery")
+	}
+
+	attempt := fake.attempts[0]
+	if !attempt.Delivered || attempt.NextAttemptAt != nil {
+		t.Errorf("attempt = %+v, want delivered without a next attempt", attempt)
+	}
+}
+
+func TestWorkerRetriesAfterServerError(t *testing.T) {
+	calls := 0
+	w, fake, advance := newTestWorker(t, func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	if _, err := w.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	failed := fake.attempts[0]
+	if failed.Delivered || failed.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("first attempt = %+v, want a failed 500", failed)
+	}
+	if failed.NextAttemptAt == nil || !failed.NextAttemptAt.Equal(failed.At.Add(Backoff(1))) {
+		t.Fatalf("next attempt = %v, want %v", failed.NextAttemptAt, failed.At.Add(Backoff(1)))
+	}
+
+	// Nothing is due until the backoff has passed.
+	if n, err := w.RunOnce(context.Background()); err != nil || n != 0 {
+		t.Fatalf("RunOnce before backoff = %d, %v, want 0, nil", n, err)
+	}
+
+	advance(Backoff(1))
+	if n, err := w.RunOnce(context.Background()); err != nil || n != 1 {
+		t.Fatalf("RunOnce after backoff = %d, %v, want 1, nil", n, err)
+	}
+	if !fake.attempts[1].Delivered {
+		t.Errorf("second attempt = %+v, want delivered", fake.attempts[1])
+	}
+}
+
+func TestWorkerGivesUpAfterMaxAttempts(t *testing.T) {
+	calls := 0
+	w, fake, advance := newTestWorker(t, func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.WriteHeader(http.StatusInternalServerError)
+	})
+
+	for range MaxAttempts + 2 {
+		if _, err := w.RunOnce(context.Background()); err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+		advance(maxBackoff)
+	}
+
+	if calls != MaxAttempts {
+		t.Fatalf("webhook called %d times, want %d", calls, MaxAttempts)
+	}
+	last := fake.attempts[len(fake.attempts)-1]
+	if last.Delivered || last.NextAttemptAt != nil {
+		t.Errorf("last attempt = %+v, want given up on", last)
+	}
+	if d := fake.deliveries[0]; !d.Failed() {
+		t.Errorf("delivery = %+v, want failed", d.WebhookDelivery)
+	}
+}